@@ -0,0 +1,202 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned (wrapped in *OpenError) when the breaker rejects a call without running it.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError is returned by Do and Check while the breaker rejects calls.
+type OpenError struct {
+	RetryAfter time.Duration // time until the breaker lets a probe through; 0 while a probe is in flight
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", ErrOpen, e.RetryAfter.Round(time.Second))
+}
+
+func (e *OpenError) Unwrap() error { return ErrOpen }
+
+// State is the breaker state.
+type State string
+
+// Breaker states.
+const (
+	StateClosed   State = "closed"    // calls run; consecutive failures are counted
+	StateOpen     State = "open"      // calls are rejected until the cooldown ends
+	StateHalfOpen State = "half-open" // a limited number of probe calls decide whether to close or reopen
+)
+
+// Config holds breaker behavior. Zero fields take the defaults below.
+type Config struct {
+	FailureThreshold int                  // consecutive failures that open the breaker; default 5
+	Cooldown         time.Duration        // time open before probing; default 30s
+	HalfOpenProbes   int                  // probe calls allowed at once while half-open; default 1
+	SuccessThreshold int                  // successful probes that close the breaker; default 1
+	IsFailure        func(err error) bool // which errors count as failures; default err != nil
+}
+
+// Snapshot is the breaker's current state, for health checks.
+type Snapshot struct {
+	State               State      `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	FailureThreshold    int        `json:"failureThreshold"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`          // when the breaker last opened; nil while closed
+	RetryAfterSeconds   int        `json:"retryAfterSeconds,omitempty"` // while open: seconds until a probe is allowed
+}
+
+// Breaker is a circuit breaker. Closed, it runs every call and counts consecutive failures; at
+// FailureThreshold it opens and rejects calls for Cooldown. Then it is half-open: up to
+// HalfOpenProbes calls run as probes. SuccessThreshold successful probes close it; a failed probe
+// reopens it for another cooldown. It is safe for concurrent use.
+type Breaker struct {
+	cfg Config
+	now func() time.Time // clock (overridden in tests)
+
+	mu         sync.Mutex
+	state      State
+	generation uint64 // bumped on every state change so results of calls from an earlier state are ignored
+	failures   int    // consecutive failures while closed
+	successes  int    // successful probes while half-open
+	probes     int    // probes in flight while half-open
+	openedAt   time.Time
+}
+
+// New returns a closed breaker.
+func New(cfg Config) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 30 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool { return err != nil }
+	}
+	return &Breaker{cfg: cfg, now: time.Now, state: StateClosed}
+}
+
+// Do runs fn unless the breaker is open, and records its result. While open (or half-open with
+// every probe slot taken) it returns *OpenError without calling fn.
+func (b *Breaker) Do(fn func() error) error {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.record(generation, b.cfg.IsFailure(err))
+	return err
+}
+
+// Check returns *OpenError if a call made now would be rejected, without reserving a probe slot.
+// Callers use it to fail fast before doing work that leads to a call.
+func (b *Breaker) Check() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.rejection()
+}
+
+// Snapshot returns the current state.
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	s := Snapshot{State: b.state, ConsecutiveFailures: b.failures, FailureThreshold: b.cfg.FailureThreshold}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		s.OpenedAt = &openedAt
+	}
+	if b.state == StateOpen {
+		s.RetryAfterSeconds = int((b.openedAt.Add(b.cfg.Cooldown).Sub(b.now()) + time.Second - 1) / time.Second)
+	}
+	return s
+}
+
+// allow admits a call, reserving a probe slot when half-open, and returns the generation to record against.
+func (b *Breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	if err := b.rejection(); err != nil {
+		return 0, err
+	}
+	if b.state == StateHalfOpen {
+		b.probes++
+	}
+	return b.generation, nil
+}
+
+// record applies the result of a call admitted in generation.
+func (b *Breaker) record(generation uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return // the state changed while the call ran
+	}
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.open()
+		}
+	case StateHalfOpen:
+		b.probes--
+		if failed {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.SuccessThreshold {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+// rejection returns the error for a call that cannot run now, or nil. Callers hold mu.
+func (b *Breaker) rejection() error {
+	switch {
+	case b.state == StateOpen:
+		return &OpenError{RetryAfter: b.openedAt.Add(b.cfg.Cooldown).Sub(b.now())}
+	case b.state == StateHalfOpen && b.probes >= b.cfg.HalfOpenProbes:
+		return &OpenError{}
+	}
+	return nil
+}
+
+// advance moves an open breaker to half-open once its cooldown has passed. Callers hold mu.
+func (b *Breaker) advance() {
+	if b.state == StateOpen && !b.now().Before(b.openedAt.Add(b.cfg.Cooldown)) {
+		b.setState(StateHalfOpen)
+	}
+}
+
+// open opens the breaker and starts the cooldown. Callers hold mu.
+func (b *Breaker) open() {
+	b.setState(StateOpen)
+	b.openedAt = b.now()
+}
+
+// setState changes state and resets the counters of the previous one. Callers hold mu.
+func (b *Breaker) setState(s State) {
+	b.state = s
+	b.generation++
+	b.successes, b.probes = 0, 0
+	if s == StateClosed {
+		b.failures = 0
+	}
+}
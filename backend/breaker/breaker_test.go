@@ -0,0 +1,98 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("down")
+
+// testBreaker returns a breaker on a manual clock and a func to advance it.
+func testBreaker(cfg Config) (*Breaker, func(time.Duration)) {
+	b := New(cfg)
+	t := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return t }
+	return b, func(d time.Duration) { t = t.Add(d) }
+}
+
+func fail() error    { return errDown }
+func succeed() error { return nil }
+
+func TestBreaker_OpensAfterThresholdAndFailsFast(t *testing.T) {
+	b, _ := testBreaker(Config{FailureThreshold: 3, Cooldown: 10 * time.Second})
+	b.Do(fail)
+	b.Do(succeed) // a success resets the count
+	for i := 0; i < 3; i++ {
+		if err := b.Do(fail); err != errDown {
+			t.Fatalf("call %d: want errDown, got %v", i, err)
+		}
+	}
+	if s := b.Snapshot(); s.State != StateOpen || s.RetryAfterSeconds != 10 {
+		t.Fatalf("want open with 10s to wait, got %+v", s)
+	}
+	calls := 0
+	err := b.Do(func() error { calls++; return nil })
+	var open *OpenError
+	if !errors.As(err, &open) || !errors.Is(err, ErrOpen) || open.RetryAfter != 10*time.Second {
+		t.Errorf("want OpenError with RetryAfter 10s, got %v", err)
+	}
+	if calls != 0 {
+		t.Error("an open breaker must not run the call")
+	}
+	if b.Check() == nil {
+		t.Error("Check: want OpenError while open")
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	b, advance := testBreaker(Config{FailureThreshold: 1, Cooldown: 10 * time.Second})
+	b.Do(fail)
+	advance(10 * time.Second)
+	if s := b.Snapshot(); s.State != StateHalfOpen {
+		t.Fatalf("after cooldown: want half-open, got %s", s.State)
+	}
+
+	// Only one probe runs at a time; others are rejected while it is in flight.
+	err := b.Do(func() error {
+		if b.Do(succeed) == nil {
+			t.Error("a second call must be rejected while the probe is in flight")
+		}
+		return errDown
+	})
+	if err != errDown || b.Snapshot().State != StateOpen {
+		t.Fatalf("a failed probe must reopen the breaker; err %v, state %s", err, b.Snapshot().State)
+	}
+
+	advance(10 * time.Second)
+	if err := b.Do(succeed); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if s := b.Snapshot(); s.State != StateClosed || s.ConsecutiveFailures != 0 {
+		t.Errorf("a successful probe must close the breaker, got %+v", s)
+	}
+}
+
+func TestBreaker_SuccessThresholdAndIsFailure(t *testing.T) {
+	ignored := errors.New("declined")
+	b, advance := testBreaker(Config{
+		FailureThreshold: 1, Cooldown: time.Second, HalfOpenProbes: 2, SuccessThreshold: 2,
+		IsFailure: func(err error) bool { return err != nil && err != ignored },
+	})
+	for i := 0; i < 3; i++ {
+		b.Do(func() error { return ignored })
+	}
+	if s := b.Snapshot(); s.State != StateClosed {
+		t.Fatalf("errors IsFailure rejects must not open the breaker, got %s", s.State)
+	}
+	b.Do(fail)
+	advance(time.Second)
+	b.Do(succeed)
+	if s := b.Snapshot(); s.State != StateHalfOpen {
+		t.Fatalf("one of two probes: want still half-open, got %s", s.State)
+	}
+	b.Do(succeed)
+	if s := b.Snapshot(); s.State != StateClosed {
+		t.Errorf("two successful probes: want closed, got %s", s.State)
+	}
+}
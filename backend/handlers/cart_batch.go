@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"SnakeGame/store"
+)
+
+// PUT /api/user/cart — replace the whole cart atomically from [{itemId, quantity}]
+func PutCartHandler(w http.ResponseWriter, r *http.Request) { // replace the whole cart
+	if r.Method != http.MethodPut {
+		return
+	}
+	var req struct { // request body for replacing the cart
+		Items []store.LineQuantity `json:"items"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil {
+		writeValidationError(w, "items required")
+		return
+	}
+	allowCORS(w)
+	expected, conditional, valid := ifMatchVersion(r)
+	if !valid {
+		writePreconditionFailed(w)
+		return
+	}
+	var err error
+	if conditional {
+		err = store.ReplaceCartIfMatch(req.Items, ownedSkins(), expected)
+	} else {
+		err = store.ReplaceCart(req.Items, ownedSkins())
+	}
+	if err != nil {
+		writeBatchError(w, err)
+		return
+	}
+	writeCart(w, http.StatusOK)
+}
+
+// POST /api/user/cart/batch — apply add/update/remove operations in one all-or-nothing request
+func PostCartBatchHandler(w http.ResponseWriter, r *http.Request) { // apply cart operations atomically
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req struct { // request body for a batch of cart operations
+		Operations []store.BatchOp `json:"operations"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || len(req.Operations) == 0 {
+		writeValidationError(w, "operations required")
+		return
+	}
+	allowCORS(w)
+	expected, conditional, valid := ifMatchVersion(r)
+	if !valid {
+		writePreconditionFailed(w)
+		return
+	}
+	var err error
+	if conditional {
+		err = store.ApplyBatchIfMatch(req.Operations, ownedSkins(), expected)
+	} else {
+		err = store.ApplyBatch(req.Operations, ownedSkins())
+	}
+	if err != nil {
+		writeBatchError(w, err)
+		return
+	}
+	writeCart(w, http.StatusOK)
+}
+
+// writeBatchError maps a replace/batch failure: 412 for a stale If-Match, otherwise 400 naming the failed entry.
+func writeBatchError(w http.ResponseWriter, err error) { // write a replace/batch error
+	if errors.Is(err, store.ErrVersionMismatch) {
+		writePreconditionFailed(w)
+		return
+	}
+	var opErr *store.OpError
+	if errors.As(err, &opErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": opErr.Error(), "index": opErr.Index})
+		return
+	}
+	writeValidationError(w, err.Error())
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"SnakeGame/models"
+	"SnakeGame/store"
+)
+
+// GET /api/catalog — list every purchasable item with its current (sale) price, plus the coin packs sold for real money
+func GetCatalogHandler(w http.ResponseWriter, r *http.Request) { // list the catalog with effective prices
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"items": models.Catalog(), "coinPacks": models.AllCoinPacks()})
+}
+
+// POST /api/admin/price-rules — schedule a sale (percentOff or salePrice between startsAt and endsAt)
+func PostPriceRuleHandler(w http.ResponseWriter, r *http.Request) { // schedule a price rule
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req models.PriceRule // request body for a price rule (id is assigned by the server)
+	if json.NewDecoder(r.Body).Decode(&req) != nil {
+		writeValidationError(w, "invalid price rule")
+		return
+	}
+	allowCORS(w)
+	rule, err := models.AddPriceRule(req)
+	if err != nil {
+		writeValidationError(w, err.Error())
+		return
+	}
+	store.CheckWishlistSales() // a sale starting now notifies right away; scheduled ones via the watcher
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// GET /api/admin/price-rules — list scheduled, active and expired price rules
+func GetPriceRulesHandler(w http.ResponseWriter, r *http.Request) { // list price rules
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"rules": models.PriceRules()})
+}
+
+// DELETE /api/admin/price-rules/{id} — cancel a price rule
+func DeletePriceRuleHandler(w http.ResponseWriter, r *http.Request) { // cancel a price rule
+	if r.Method != http.MethodDelete {
+		return
+	}
+	allowCORS(w)
+	if err := models.RemovePriceRule(r.PathValue("id")); err != nil {
+		if errors.Is(err, models.ErrPriceRuleNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeValidationError(w, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/admin/stock/{id} — limit an item to a global number of units ({"total": 500})
+func PutStockHandler(w http.ResponseWriter, r *http.Request) { // set the global stock of a limited item
+	if r.Method != http.MethodPut {
+		return
+	}
+	var req struct { // request body for a stock limit
+		Total *int `json:"total"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.Total == nil {
+		writeValidationError(w, "total required")
+		return
+	}
+	allowCORS(w)
+	level, err := models.SetStock(r.PathValue("id"), *req.Total)
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownItem) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeValidationError(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(level)
+}
+
+// DELETE /api/admin/stock/{id} — remove the stock limit (item becomes unlimited)
+func DeleteStockHandler(w http.ResponseWriter, r *http.Request) { // remove the stock limit of an item
+	if r.Method != http.MethodDelete {
+		return
+	}
+	allowCORS(w)
+	models.ClearStock(r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
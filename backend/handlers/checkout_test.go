@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"SnakeGame/models"
+	"SnakeGame/store"
+)
+
+// resetPlayer restores the starting player and an empty cart.
+func resetPlayer(t *testing.T) {
+	t.Helper()
+	playerMu.Lock()
+	player.Balance = 200
+	player.OwnedSkins = []string{"default"}
+	player.EquippedSkin = "default"
+	player.ExtraLives = 0
+	player.Gifts = []gift{}
+	playerMu.Unlock()
+	store.ClearCart()
+}
+
+// serve runs a handler against a JSON request and returns the recorder.
+func serve(h http.HandlerFunc, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func TestCheckout_IfMatch(t *testing.T) {
+	resetPlayer(t)
+	rec := serve(PostCartItemsHandler, http.MethodPost, "/api/user/cart/items", `{"itemId":"skin_gold"}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add to cart: status %d", rec.Code)
+	}
+	staleTag := rec.Header().Get("ETag")
+	if staleTag == "" {
+		t.Fatal("cart mutation must return an ETag")
+	}
+
+	// A second tab adds a life: the first tab's ETag is now stale.
+	rec = serve(PostCartItemsHandler, http.MethodPost, "/api/user/cart/items", `{"itemId":"extra_life"}`, nil)
+	freshTag := rec.Header().Get("ETag")
+	if freshTag == staleTag {
+		t.Fatal("ETag must change when the cart changes")
+	}
+
+	rec = serve(CheckoutHandler, http.MethodPost, "/api/user/orders", `{}`, map[string]string{"If-Match": staleTag})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: want 412, got %d %s", rec.Code, rec.Body)
+	}
+	if player.Balance != 200 {
+		t.Fatalf("412 must not charge; balance %d", player.Balance)
+	}
+
+	rec = serve(CheckoutHandler, http.MethodPost, "/api/user/orders", `{}`, map[string]string{"If-Match": freshTag})
+	var out map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusOK || out["Status"] != "Success" {
+		t.Fatalf("fresh If-Match: want success, got %d %s", rec.Code, rec.Body)
+	}
+	if player.Balance != 50 {
+		t.Errorf("balance: want 50, got %d", player.Balance)
+	}
+}
+
+func TestPatchCartItem_IfMatch(t *testing.T) {
+	resetPlayer(t)
+	store.AddToCart("extra_life", nil)
+	items, _, version := store.GetCartWithVersion()
+	id := items[0].ID
+	patch := func(w http.ResponseWriter, r *http.Request) { PatchCartItemHandler(w, r, id) }
+
+	rec := serve(patch, http.MethodPatch, "/api/user/cart/items/"+id, `{"quantity":2}`, map[string]string{"If-Match": cartETag(version + 1)})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: want 412, got %d", rec.Code)
+	}
+	rec = serve(patch, http.MethodPatch, "/api/user/cart/items/"+id, `{"quantity":2}`, map[string]string{"If-Match": cartETag(version)})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("current If-Match: want 200, got %d %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("ETag") != cartETag(version+1) {
+		t.Errorf("ETag after update: want %s, got %s", cartETag(version+1), rec.Header().Get("ETag"))
+	}
+}
+
+func TestCartQuote_MatchesCheckout(t *testing.T) {
+	resetPlayer(t)
+	store.AddToCart("skin_gold", nil)
+	store.AddToCart("extra_life", nil)
+	store.AddToCart("extra_life", nil)
+	playerMu.Lock()
+	player.OwnedSkins = append(player.OwnedSkins, "skin_gold") // bought elsewhere after it was added
+	playerMu.Unlock()
+
+	rec := serve(GetCartQuoteHandler, http.MethodGet, "/api/user/cart/quote", "", nil)
+	var out struct {
+		Quote       checkoutQuote `json:"quote"`
+		CanCheckout bool          `json:"canCheckout"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode quote: %v", err)
+	}
+	q := out.Quote
+	if !out.CanCheckout || q.Total != 100 || q.BalanceAfter != 100 || len(q.Lines) != 1 || len(q.Skipped) != 1 || q.Skipped[0].Reason != "already owned" {
+		t.Fatalf("unexpected quote: %+v", out)
+	}
+	if store.CartCount() != 2 {
+		t.Fatal("quote must not modify the cart")
+	}
+
+	rec = serve(CheckoutHandler, http.MethodPost, "/api/user/orders", `{}`, nil)
+	var res map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	if res["Status"] != "Success" || int(res["Charged"].(float64)) != q.Total || player.Balance != q.BalanceAfter {
+		t.Errorf("checkout charged %v (balance %d), quote said %d (balance %d)", res["Charged"], player.Balance, q.Total, q.BalanceAfter)
+	}
+}
+
+func TestCartResponse_CouponSkipsOwnedSkins(t *testing.T) {
+	resetPlayer(t)
+	if _, err := store.CreateCoupon(store.Coupon{Code: "tenoff", PercentOff: 10}); err != nil {
+		t.Fatalf("CreateCoupon: %v", err)
+	}
+	store.AddToCart("skin_gold", nil)
+	store.AddToCart("extra_life", nil)
+	store.AddToCart("extra_life", nil)
+	if _, err := store.ApplyCoupon("tenoff", player.ID); err != nil {
+		t.Fatalf("ApplyCoupon: %v", err)
+	}
+	defer store.RemoveCoupon()
+	playerMu.Lock()
+	player.OwnedSkins = append(player.OwnedSkins, "skin_gold") // owned skins are not charged, so not discounted
+	playerMu.Unlock()
+
+	rec := serve(GetCartQuoteHandler, http.MethodGet, "/api/user/cart/quote", "", nil)
+	var out struct {
+		Quote checkoutQuote `json:"quote"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode quote: %v", err)
+	}
+	items, total := store.GetCart()
+	coupon := cartResponse(items, total)["coupon"].(map[string]interface{})
+	if coupon["discount"] != out.Quote.CouponDiscount || out.Quote.CouponDiscount != 10 {
+		t.Errorf("cart coupon discount %v, quote %d, want 10", coupon["discount"], out.Quote.CouponDiscount)
+	}
+}
+
+func TestCheckout_Gift(t *testing.T) {
+	resetPlayer(t)
+	rec := serve(PostPlayersHandler, http.MethodPost, "/api/players", `{"name":"Friend"}`, nil)
+	var friend playerState
+	if err := json.Unmarshal(rec.Body.Bytes(), &friend); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register player: %d %s", rec.Code, rec.Body)
+	}
+	giftBody := `{"recipientId":"` + friend.ID + `","message":"happy birthday"}`
+
+	// The friend already owns the gold skin: the gift is rejected up front, nothing is charged.
+	playerMu.Lock()
+	players[friend.ID].OwnedSkins = append(players[friend.ID].OwnedSkins, "skin_gold")
+	playerMu.Unlock()
+	store.AddToCart("skin_gold", nil)
+	store.AddToCart("extra_life", nil)
+	rec = serve(CheckoutHandler, http.MethodPost, "/api/user/orders", giftBody, nil)
+	var out map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if out["Status"] != "Fail" || out["AlreadyOwned"] == nil || player.Balance != 200 || store.CartCount() != 2 {
+		t.Fatalf("gift of an owned skin: want rejection, got %s (balance %d)", rec.Body, player.Balance)
+	}
+
+	// Without the conflicting skin the sender pays and the friend receives the lives.
+	items, _ := store.GetCart()
+	store.RemoveCartItemByID(items[0].ID)
+	rec = serve(CheckoutHandler, http.MethodPost, "/api/user/orders", giftBody, nil)
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if out["Status"] != "Success" {
+		t.Fatalf("gift checkout: %s", rec.Body)
+	}
+	playerMu.RLock()
+	defer playerMu.RUnlock()
+	got := players[friend.ID]
+	if player.Balance != 150 || player.ExtraLives != 0 || got.ExtraLives != 1 || got.Balance != 200 {
+		t.Errorf("sender balance %d lives %d, recipient balance %d lives %d", player.Balance, player.ExtraLives, got.Balance, got.ExtraLives)
+	}
+	if len(got.Gifts) != 1 || got.Gifts[0].FromName != player.Name || got.Gifts[0].Message != "happy birthday" {
+		t.Errorf("recipient gift records: %+v", got.Gifts)
+	}
+}
+
+func TestCheckout_PriceChangeConfirmedWithSameKey(t *testing.T) {
+	resetPlayer(t)
+	if err := store.AddToCart("skin_ice", nil); err != nil {
+		t.Fatalf("AddToCart: %v", err)
+	}
+	sale, err := models.AddPriceRule(models.PriceRule{ItemIDs: []string{"skin_ice"}, PercentOff: 50, EndsAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("AddPriceRule: %v", err)
+	}
+	t.Cleanup(func() { models.RemovePriceRule(sale.ID) })
+
+	headers := map[string]string{"Idempotency-Key": "checkout-reprice-1"}
+	rec := serve(CheckoutHandler, http.MethodPost, "/api/user/orders", `{}`, headers)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("price change: want 409, got %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(CheckoutHandler, http.MethodPost, "/api/user/orders", `{}`, headers)
+	var out map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusOK || out["Status"] != "Success" {
+		t.Fatalf("confirming with the same key: want success, got %d %s", rec.Code, rec.Body)
+	}
+	if player.Balance != 150 {
+		t.Errorf("balance: want 150 after paying the sale price, got %d", player.Balance)
+	}
+}
+
+func TestCheckout_StaleIfMatchRetriedWithSameKey(t *testing.T) {
+	resetPlayer(t)
+	rec := serve(PostCartItemsHandler, http.MethodPost, "/api/user/cart/items", `{"itemId":"extra_life"}`, nil)
+	staleTag := rec.Header().Get("ETag")
+	rec = serve(PostCartItemsHandler, http.MethodPost, "/api/user/cart/items", `{"itemId":"skin_ice"}`, nil)
+	freshTag := rec.Header().Get("ETag")
+
+	rec = serve(CheckoutHandler, http.MethodPost, "/api/user/orders", `{}`,
+		map[string]string{"Idempotency-Key": "checkout-stale-1", "If-Match": staleTag})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: want 412, got %d %s", rec.Code, rec.Body)
+	}
+	// The tab reloads the cart and retries the same checkout.
+	rec = serve(CheckoutHandler, http.MethodPost, "/api/user/orders", `{}`,
+		map[string]string{"Idempotency-Key": "checkout-stale-1", "If-Match": freshTag})
+	var out map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusOK || out["Status"] != "Success" {
+		t.Fatalf("retry after reload: want success, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"SnakeGame/breaker"
+	"SnakeGame/ledger"
+	"SnakeGame/models"
+	"SnakeGame/orders"
+	"SnakeGame/payment"
+	"SnakeGame/retry"
+	"SnakeGame/store"
+)
+
+var (
+	gatewayMu      sync.RWMutex                                                    // protects gateway and paymentBreaker
+	gateway        payment.Gateway  = &payment.StubGateway{}                       // charges coin pack purchases
+	paymentBreaker *breaker.Breaker = breaker.New(breakerConfig(breaker.Config{})) // trips when the gateway is down
+)
+
+// breakerConfig counts only provider outages as breaker failures, so declined cards never open it.
+func breakerConfig(cfg breaker.Config) breaker.Config {
+	cfg.IsFailure = payment.IsOutage
+	return cfg
+}
+
+// SetPaymentGateway sets the gateway used for real-money purchases (e.g. a payment.HTTPGateway).
+func SetPaymentGateway(gw payment.Gateway) {
+	gatewayMu.Lock()
+	defer gatewayMu.Unlock()
+	gateway = gw
+}
+
+// SetPaymentBreaker replaces the gateway's circuit breaker (thresholds and cooldown), starting closed.
+func SetPaymentBreaker(cfg breaker.Config) {
+	gatewayMu.Lock()
+	defer gatewayMu.Unlock()
+	paymentBreaker = breaker.New(breakerConfig(cfg))
+}
+
+// gatewayBreaker returns the gateway's circuit breaker.
+func gatewayBreaker() *breaker.Breaker {
+	gatewayMu.RLock()
+	defer gatewayMu.RUnlock()
+	return paymentBreaker
+}
+
+// paymentGateway returns the configured gateway behind its circuit breaker, or a timing-out stub when
+// the request asks to simulate a gateway timeout (X-Simulate-Payment-Timeout: true). The simulated
+// timeout bypasses the breaker so testing retries does not fail real purchases fast.
+func paymentGateway(r *http.Request) payment.Gateway {
+	if r.Header.Get("X-Simulate-Payment-Timeout") == "true" {
+		return &payment.StubGateway{SimulateTimeout: true}
+	}
+	gatewayMu.RLock()
+	defer gatewayMu.RUnlock()
+	return &payment.BreakerGateway{Gateway: gateway, Breaker: paymentBreaker}
+}
+
+// paymentRetry runs a gateway call with retry (exponential backoff). Stop conditions: success,
+// non-retryable error, max attempts, or context cancelled. Errors classified as not retryable
+// (declines, invalid requests; see classifyPayment) stop at once. A pending payment
+// (payment.ErrPending) is accepted by the provider and is not retried, and neither is a call
+// rejected by an open circuit breaker. Rate limits wait at least the provider's Retry-After.
+func paymentRetry(ctx context.Context, call func() error) error {
+	cfg := retry.DefaultConfig()
+	cfg.MaxAttempts = 5
+	cfg.InitialDelay = 100 * time.Millisecond
+	cfg.MaxDelay = 5 * time.Second
+	return retry.Do(ctx, cfg, func() error {
+		err := call()
+		if err != nil && (errors.Is(err, payment.ErrPending) || errors.Is(err, breaker.ErrOpen) || !classifyPayment(err).retryable) {
+			return &retry.NonRetryableError{Err: err}
+		}
+		return err
+	})
+}
+
+// authorizeWithRetry holds amountCents through the gateway with paymentRetry and returns the
+// authorization's transaction id. Every attempt uses the same idempotency key, so retries never
+// place a second hold. The id is also returned with payment.ErrPending.
+func authorizeWithRetry(ctx context.Context, gw payment.Gateway, amountCents int, idempotencyKey string) (string, error) {
+	var txn string
+	err := paymentRetry(ctx, func() error {
+		id, err := gw.Authorize(ctx, amountCents, idempotencyKey)
+		if id != "" {
+			txn = id
+		}
+		return err
+	})
+	return txn, err
+}
+
+// newPaymentKey returns a gateway idempotency key for a request that did not send one, so the
+// retries of a single request are still deduplicated by the provider.
+func newPaymentKey() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "pay_" + hex.EncodeToString(b)
+}
+
+// POST /api/user/coin-packs/{id}/purchase — buy a coin pack with real money: the pack price in cents
+// is authorized at the gateway, the coins are credited and the authorization is captured. Uses the
+// Idempotency-Key header like checkout, but the key is claimed first: a second request with a key
+// that is still being processed waits for the first and gets its response.
+// While the gateway's circuit breaker is open it fails fast with 503 and Retry-After, without
+// creating an order. That response, and any other retryable outage (see retryableOutage), is not
+// cached, so the same key can be retried later.
+// Set header X-Simulate-Payment-Timeout: true to simulate gateway timeout (for testing retry).
+func PostCoinPackPurchaseHandler(w http.ResponseWriter, r *http.Request) { // buy a coin pack
+	if r.Method != http.MethodPost {
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+
+	// Claim the key before any work: a concurrent request with the same key waits for this one and
+	// replays its response, so only one order is created and fulfilled per key.
+	key := r.Header.Get("Idempotency-Key") // idempotency key
+	if status, cached, ok := claimIdempotency(key); ok {
+		w.WriteHeader(status)
+		w.Write(cached)
+		return
+	}
+	defer releaseIdempotency(key) // responses that are not cached leave the key free for a retry
+	pack, ok := models.CoinPackByID(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "coin pack not found")
+		return
+	}
+
+	if err := gatewayBreaker().Check(); err != nil && r.Header.Get("X-Simulate-Payment-Timeout") != "true" {
+		writeGatewayUnavailable(w, err)
+		return
+	}
+
+	gw := paymentGateway(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	status, body, retryAfter := buyCoinPack(ctx, gw, pack, key)
+	if key != "" && !retryableOutage(status) {
+		setIdempotency(key, status, body)
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// buyCoinPack creates an order for pack, authorizes its price at the gateway and settles the order
+// (settleOrder: credit the coins, then capture), returning the response and the Retry-After seconds
+// to send (0 for none). When the gateway confirms asynchronously the order stays pending (202) and
+// the coins are withheld until the payment webhook reports the outcome. A failed authorization
+// answers with the status and Code of classifyPayment.
+func buyCoinPack(ctx context.Context, gw payment.Gateway, pack models.CoinPack, idempotencyKey string) (statusCode int, body []byte, retryAfter int) {
+	paymentKey := idempotencyKey
+	if paymentKey == "" {
+		paymentKey = newPaymentKey()
+	}
+	order, err := orders.Create(orders.Order{
+		PlayerID: player.ID, PackID: pack.ID, Coins: pack.Coins, AmountCents: pack.PriceCents, Currency: pack.Currency, PaymentKey: paymentKey,
+	})
+	if err != nil {
+		out := map[string]interface{}{ // response body for a key that already has a live order
+			"Status":  "Fail",
+			"Message": "An order with this Idempotency-Key is already pending or complete.",
+			"Code":    "duplicate_order",
+			"OrderID": order.ID,
+		}
+		body, _ = json.Marshal(out)
+		return http.StatusConflict, body, 0
+	}
+	txn, err := authorizeWithRetry(ctx, gw, pack.PriceCents, paymentKey)
+	if txn != "" {
+		orders.SetPayment(order.ID, txn, orders.PaymentAuthorized)
+	}
+	if rr, ok := gw.(payment.RouteReporter); ok {
+		if name, ok := rr.RouteFor(paymentKey); ok {
+			orders.SetGateway(order.ID, name)
+		}
+	}
+	if errors.Is(err, payment.ErrPending) {
+		out := map[string]interface{}{ // response body for a payment awaiting confirmation
+			"Status":  "Pending",
+			"Message": "Payment is being confirmed. Coins will be added once it completes.",
+			"OrderID": order.ID,
+		}
+		body, _ = json.Marshal(out)
+		return http.StatusAccepted, body, 0
+	}
+	if errors.Is(err, breaker.ErrOpen) {
+		orders.Transition(order.ID, orders.StatusFailed, err.Error())
+		retryAfter = breakerRetryAfter(err)
+		out := map[string]interface{}{ // response body for a gateway that was failed fast
+			"Status":     "Fail",
+			"Message":    "Payment provider is unavailable. Please try again later.",
+			"Code":       "circuit_open",
+			"OrderID":    order.ID,
+			"RetryAfter": retryAfter,
+		}
+		body, _ = json.Marshal(out)
+		return http.StatusServiceUnavailable, body, retryAfter
+	}
+	if err != nil {
+		failure := classifyPayment(err)
+		orders.Transition(order.ID, orders.StatusFailed, failure.code+": "+err.Error())
+		out := map[string]interface{}{ // response body for a failed authorization
+			"Status":  "Fail",
+			"Message": failure.message,
+			"Code":    failure.code,
+			"OrderID": order.ID,
+		}
+		if failure.retryable {
+			retryAfter = paymentRetryAfter(err)
+		}
+		if retryAfter > 0 {
+			out["RetryAfter"] = retryAfter
+		}
+		body, _ = json.Marshal(out)
+		return failure.status, body, retryAfter
+	}
+
+	_, balance, err := settleOrder(ctx, gw, order.ID)
+	if err != nil {
+		out := map[string]interface{}{ // response body for an order that could not be fulfilled
+			"Status":  "Fail",
+			"Message": err.Error(),
+			"OrderID": order.ID,
+		}
+		body, _ = json.Marshal(out)
+		return http.StatusConflict, body, 0
+	}
+	out := map[string]interface{}{ // response body for a completed coin pack purchase
+		"Status":        "Success",
+		"Message":       fmt.Sprintf("%d coins added!", pack.Coins),
+		"OrderID":       order.ID,
+		"PackID":        pack.ID,
+		"ChargedCents":  pack.PriceCents,
+		"Currency":      pack.Currency,
+		"CoinsCredited": pack.Coins,
+		"Balance":       balance,
+	}
+	body, _ = json.Marshal(out)
+	return http.StatusOK, body, 0
+}
+
+// writeGatewayUnavailable writes the fail-fast response for an open circuit breaker: 503 with
+// Retry-After set to the seconds left in the cooldown (at least 1).
+func writeGatewayUnavailable(w http.ResponseWriter, err error) {
+	seconds := breakerRetryAfter(err)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{ // response body for an open circuit breaker
+		"Status":     "Fail",
+		"Message":    fmt.Sprintf("Payment provider is unavailable. Please try again in %d seconds.", seconds),
+		"Code":       "circuit_open",
+		"RetryAfter": seconds,
+	})
+}
+
+// breakerRetryAfter returns the seconds left in an open breaker's cooldown (from *breaker.OpenError),
+// rounded up and at least 1.
+func breakerRetryAfter(err error) int {
+	var open *breaker.OpenError
+	if errors.As(err, &open) && open.RetryAfter > time.Second {
+		return int((open.RetryAfter + time.Second - 1) / time.Second)
+	}
+	return 1
+}
+
+// retryableOutage reports whether a purchase response is a retryable outage: rate limited (429),
+// provider or breaker unavailable (503) or timed out (504). The order failed and the gateway dedupes
+// the payment key, so a retry with the same key is safe; these responses are never cached.
+func retryableOutage(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// settleOrder fulfills an order whose payment was authorized, then captures the authorization, so
+// the player is only charged for coins they received. When the order cannot be fulfilled (e.g. its
+// player no longer exists) the authorization is voided and the order fails. Capture and void are
+// retried and run even if ctx is cancelled, since the coins have been credited (or refused) by then;
+// a capture that still fails is logged and left for reconciliation to flag. An order without a
+// recorded authorization is only fulfilled.
+func settleOrder(ctx context.Context, gw payment.Gateway, id string) (orders.Order, int, error) {
+	order, balance, err := fulfillOrder(id)
+	if order.TransactionID == "" {
+		return order, balance, err
+	}
+	settleCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err != nil {
+		if order.Status == orders.StatusPending {
+			if verr := paymentRetry(settleCtx, func() error { return gw.Void(settleCtx, order.TransactionID) }); verr != nil {
+				log.Printf("order %s: void %s: %v", order.ID, order.TransactionID, verr)
+			} else {
+				orders.SetPayment(order.ID, "", orders.PaymentVoided)
+			}
+			orders.Transition(order.ID, orders.StatusFailed, err.Error())
+		}
+		return order, 0, err
+	}
+	if cerr := paymentRetry(settleCtx, func() error { return gw.Capture(settleCtx, order.TransactionID) }); cerr != nil {
+		log.Printf("order %s: capture %s: %v", order.ID, order.TransactionID, cerr)
+	} else {
+		orders.SetPayment(order.ID, "", orders.PaymentCaptured)
+		order.PaymentState = orders.PaymentCaptured
+	}
+	return order, balance, nil
+}
+
+// fulfillOrder marks a pending order succeeded and credits its coins to the buyer, returning the new
+// balance. The state machine only lets a pending order succeed once, so coins are never credited
+// twice. If the buyer cannot be found the order is returned unchanged (still pending) with an error.
+func fulfillOrder(id string) (orders.Order, int, error) {
+	order, err := orders.Get(id)
+	if err != nil {
+		return order, 0, err
+	}
+	playerMu.Lock()
+	p, ok := lookupPlayer(order.PlayerID)
+	if !ok {
+		playerMu.Unlock()
+		return order, 0, fmt.Errorf("player %s not found", order.PlayerID)
+	}
+	order, err = orders.Transition(id, orders.StatusSucceeded, "")
+	if err != nil {
+		playerMu.Unlock()
+		return order, 0, err
+	}
+	previous := p.Balance
+	p.Balance += order.Coins
+	balance := p.Balance
+	ledger.Record(ledger.TypeCoinPack, ledger.AccountPayments, p.ID, order.Coins, order.ID)
+	publishBalance(p)
+	playerMu.Unlock()
+	if p == player {
+		store.CheckWishlistAffordability(previous, balance) // notify about wishlisted items that became affordable
+	}
+	return order, balance, nil
+}
+
+// GET /api/user/coin-packs/orders/{id} — a coin pack order and its payment status
+func GetCoinPackOrderHandler(w http.ResponseWriter, r *http.Request) { // get a coin pack order
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	order, err := orders.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
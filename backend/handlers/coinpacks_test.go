@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"SnakeGame/models"
+	"SnakeGame/orders"
+	"SnakeGame/payment"
+	"SnakeGame/retry"
+	"SnakeGame/store"
+)
+
+// recordingGateway records the amounts charged (or authorized and captured) and fails charges and
+// authorizations with err when set.
+type recordingGateway struct {
+	charged []int
+	held    map[string]int // transaction id -> amount authorized and not settled
+	err     error
+}
+
+func (g *recordingGateway) Charge(ctx context.Context, amountCents int, idempotencyKey string) error {
+	if g.err != nil {
+		return g.err
+	}
+	g.charged = append(g.charged, amountCents)
+	return nil
+}
+
+func (g *recordingGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	if g.err != nil {
+		return "", g.err
+	}
+	if g.held == nil {
+		g.held = map[string]int{}
+	}
+	txn := fmt.Sprintf("auth_%d", len(g.held)+len(g.charged)+1)
+	g.held[txn] = amountCents
+	return txn, nil
+}
+
+func (g *recordingGateway) Capture(ctx context.Context, transactionID string) error {
+	if amount, ok := g.held[transactionID]; ok {
+		g.charged = append(g.charged, amount)
+		delete(g.held, transactionID)
+	}
+	return nil
+}
+
+func (g *recordingGateway) Void(ctx context.Context, transactionID string) error {
+	delete(g.held, transactionID)
+	return nil
+}
+
+func TestCoinPack_ChargesCentsAndCreditsCoins(t *testing.T) {
+	resetPlayer(t)
+	pack, _ := models.CoinPackByID("coins_500")
+	gw := &recordingGateway{}
+	status, body, _ := buyCoinPack(context.Background(), gw, pack, "")
+	if status != http.StatusOK {
+		t.Fatalf("buy pack: %d %s", status, body)
+	}
+	if len(gw.charged) != 1 || gw.charged[0] != 499 {
+		t.Errorf("gateway charges: want [499], got %v", gw.charged)
+	}
+	if player.Balance != 700 {
+		t.Errorf("balance: want 700, got %d", player.Balance)
+	}
+
+	gw = &recordingGateway{err: &retry.NonRetryableError{Err: errors.New("card declined")}}
+	if status, _, _ := buyCoinPack(context.Background(), gw, pack, ""); status != http.StatusServiceUnavailable {
+		t.Errorf("failed charge: want 503, got %d", status)
+	}
+	if player.Balance != 700 {
+		t.Errorf("a failed charge must not credit coins; balance %d", player.Balance)
+	}
+}
+
+func TestCoinPack_NotInCoinCatalog(t *testing.T) {
+	resetPlayer(t)
+	if err := store.AddToCart("coins_500", nil); !errors.Is(err, store.ErrUnknownItem) {
+		t.Errorf("coin packs cannot be bought with coins: want ErrUnknownItem, got %v", err)
+	}
+}
+
+func TestCoinPack_EndToEndThroughHTTPProvider(t *testing.T) {
+	resetPlayer(t)
+	fake := payment.NewFakeProvider()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	SetPaymentGateway(&payment.HTTPGateway{BaseURL: srv.URL})
+	t.Cleanup(func() { SetPaymentGateway(&payment.StubGateway{}) })
+
+	fake.FailNext(payment.FakeFailure{Status: http.StatusServiceUnavailable, Code: "provider_down"}) // first attempt fails, the retry succeeds
+	buy := withPath(PostCoinPackPurchaseHandler, "coins_500")
+	headers := map[string]string{"Idempotency-Key": "pack-e2e-1"}
+	rec := serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", headers)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("purchase: %d %s", rec.Code, rec.Body)
+	}
+	charges := fake.Charges()
+	if len(charges) != 1 || charges[0].Amount != 499 || charges[0].IdempotencyKey != "pack-e2e-1" {
+		t.Errorf("provider charges: %+v", charges)
+	}
+	if player.Balance != 700 {
+		t.Errorf("balance: want 700, got %d", player.Balance)
+	}
+}
+
+// lostResponseGateway authorizes through the stub but reports a timeout the first time, like a
+// provider response lost on the network after the amount was held.
+type lostResponseGateway struct {
+	*payment.StubGateway
+	lost bool
+}
+
+func (g *lostResponseGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	txn, err := g.StubGateway.Authorize(ctx, amountCents, idempotencyKey)
+	if !g.lost {
+		g.lost = true
+		return "", payment.ErrTimeout
+	}
+	return txn, err
+}
+
+func TestCoinPack_RetryNeverDoubleCharges(t *testing.T) {
+	resetPlayer(t)
+	stub := &payment.StubGateway{}
+	pack, _ := models.CoinPackByID("coins_1200")
+	status, body, _ := buyCoinPack(context.Background(), &lostResponseGateway{StubGateway: stub}, pack, "")
+	if status != http.StatusOK {
+		t.Fatalf("buy pack: %d %s", status, body)
+	}
+	if charges := stub.Charges(); len(charges) != 1 || charges[0].AmountCents != 999 {
+		t.Errorf("the retry must reuse the key and not charge again; charges %+v", charges)
+	}
+	if player.Balance != 1400 {
+		t.Errorf("balance: want 1400, got %d", player.Balance)
+	}
+}
+
+// vanishingPlayerGateway authorizes through the stub, then removes the buyer before the order is
+// fulfilled (e.g. the account was deleted while the payment was in flight).
+type vanishingPlayerGateway struct {
+	*payment.StubGateway
+}
+
+func (g *vanishingPlayerGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	playerMu.Lock()
+	delete(players, player.ID)
+	playerMu.Unlock()
+	return g.StubGateway.Authorize(ctx, amountCents, idempotencyKey)
+}
+
+func TestCoinPack_CapturesAfterFulfillment(t *testing.T) {
+	resetPlayer(t)
+	stub := &payment.StubGateway{}
+	pack, _ := models.CoinPackByID("coins_500")
+	status, body, _ := buyCoinPack(context.Background(), stub, pack, "pack-capture-1")
+	if status != http.StatusOK {
+		t.Fatalf("buy pack: %d %s", status, body)
+	}
+	auths := stub.Authorizations()
+	if len(auths) != 1 || auths[0].State != payment.AuthCaptured || auths[0].AmountCents != 499 {
+		t.Errorf("want one captured authorization of 499, got %+v", auths)
+	}
+	if o, _ := orders.ByPaymentKey("pack-capture-1"); o.TransactionID != auths[0].TransactionID || o.PaymentState != orders.PaymentCaptured {
+		t.Errorf("order payment: want %s captured, got %q %q", auths[0].TransactionID, o.TransactionID, o.PaymentState)
+	}
+}
+
+func TestCoinPack_VoidsWhenFulfillmentFails(t *testing.T) {
+	resetPlayer(t)
+	t.Cleanup(func() {
+		playerMu.Lock()
+		players[player.ID] = player
+		playerMu.Unlock()
+	})
+	stub := &payment.StubGateway{}
+	pack, _ := models.CoinPackByID("coins_500")
+	status, body, _ := buyCoinPack(context.Background(), &vanishingPlayerGateway{stub}, pack, "pack-void-1")
+	if status != http.StatusConflict {
+		t.Fatalf("unfulfillable order: want 409, got %d %s", status, body)
+	}
+	if auths := stub.Authorizations(); len(auths) != 1 || auths[0].State != payment.AuthVoided {
+		t.Errorf("the authorization must be voided, got %+v", auths)
+	}
+	if charges := stub.Charges(); len(charges) != 0 {
+		t.Errorf("nothing may be charged, got %+v", charges)
+	}
+	o, _ := orders.ByPaymentKey("pack-void-1")
+	if o.Status != orders.StatusFailed || o.PaymentState != orders.PaymentVoided {
+		t.Errorf("order: want failed and voided, got %s %s", o.Status, o.PaymentState)
+	}
+	if player.Balance != 200 {
+		t.Errorf("balance: want 200, got %d", player.Balance)
+	}
+}
+
+func TestCoinPack_OrderRecordsRoutedGateway(t *testing.T) {
+	resetPlayer(t)
+	primary := &recordingGateway{err: &payment.ProviderError{StatusCode: http.StatusServiceUnavailable, Err: payment.ErrUnavailable}}
+	secondary := &recordingGateway{}
+	router := &payment.Router{
+		Primary:   payment.Route{Name: "acme", Gateway: primary},
+		Secondary: payment.Route{Name: "globex", Gateway: secondary},
+		Policy:    payment.PolicyFailover,
+	}
+	pack, _ := models.CoinPackByID("coins_500")
+	status, body, _ := buyCoinPack(context.Background(), router, pack, "pack-route-1")
+	if status != http.StatusOK {
+		t.Fatalf("buy pack: %d %s", status, body)
+	}
+	order, err := orders.ByPaymentKey("pack-route-1")
+	if err != nil || order.Gateway != "globex" {
+		t.Errorf("order must record the gateway that charged it: %+v %v", order, err)
+	}
+	if len(primary.charged) != 0 || len(secondary.charged) != 1 {
+		t.Errorf("want only the secondary charged; primary %v, secondary %v", primary.charged, secondary.charged)
+	}
+}
+
+func TestCoinPack_ConcurrentSameKeyCreditsOnce(t *testing.T) {
+	resetPlayer(t)
+	stub := &payment.StubGateway{SimulateDelay: 50 * time.Millisecond}
+	SetPaymentGateway(stub)
+	t.Cleanup(func() { SetPaymentGateway(&payment.StubGateway{}) })
+
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, 2)
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = serve(withPath(PostCoinPackPurchaseHandler, "coins_500"), http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "",
+				map[string]string{"Idempotency-Key": "pack-concurrent-1"})
+		}()
+	}
+	wg.Wait()
+	for _, rec := range recs {
+		if rec.Code != http.StatusOK || rec.Body.String() != recs[0].Body.String() {
+			t.Errorf("both requests must get the one purchase's response; got %d %s", rec.Code, rec.Body)
+		}
+	}
+	if player.Balance != 700 {
+		t.Errorf("balance: want 700 (one pack credited), got %d", player.Balance)
+	}
+	n := 0
+	for _, o := range orders.List() {
+		if o.PaymentKey == "pack-concurrent-1" {
+			n++
+		}
+	}
+	if n != 1 || len(stub.Authorizations()) != 1 || len(stub.Charges()) != 1 {
+		t.Errorf("want one order, authorization and capture; got %d orders, %+v, %+v", n, stub.Authorizations(), stub.Charges())
+	}
+}
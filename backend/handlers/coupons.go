@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"SnakeGame/store"
+)
+
+// POST /api/user/cart/coupon — attach a promo code to the cart
+func PostCartCouponHandler(w http.ResponseWriter, r *http.Request) { // attach a promo code to the cart
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req struct { // request body for attaching a coupon
+		Code string `json:"code"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.Code == "" {
+		writeValidationError(w, "invalid code")
+		return
+	}
+	allowCORS(w)
+	if _, err := store.ApplyCoupon(req.Code, player.ID); err != nil {
+		if errors.Is(err, store.ErrCouponNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeValidationError(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeCart(w, http.StatusOK)
+}
+
+// DELETE /api/user/cart/coupon — remove the attached promo code
+func DeleteCartCouponHandler(w http.ResponseWriter, r *http.Request) { // remove the attached promo code
+	if r.Method != http.MethodDelete {
+		return
+	}
+	allowCORS(w)
+	if err := store.RemoveCoupon(); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeCart(w, http.StatusOK)
+}
+
+// POST /api/admin/coupons — create a promo code
+func PostCouponHandler(w http.ResponseWriter, r *http.Request) { // create a promo code
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req store.Coupon // request body for a coupon definition
+	if json.NewDecoder(r.Body).Decode(&req) != nil {
+		writeValidationError(w, "invalid coupon")
+		return
+	}
+	allowCORS(w)
+	c, err := store.CreateCoupon(req)
+	if err != nil {
+		if errors.Is(err, store.ErrCouponExists) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeValidationError(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// GET /api/admin/coupons — list promo codes with redemption counts
+func GetCouponsHandler(w http.ResponseWriter, r *http.Request) { // list promo codes
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"coupons": store.Coupons()})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SnakeGame/payment"
+)
+
+// faultGateway injects payment failures for QA; nil unless EnablePaymentFaults was called. Protected by gatewayMu.
+var faultGateway *payment.FaultGateway
+
+// EnablePaymentFaults wraps the configured payment gateway in a fault injector that the test-only
+// /api/test/payment-faults endpoints control. It starts passing every charge through. Call it after
+// SetPaymentGateway, and never in production.
+func EnablePaymentFaults() {
+	gatewayMu.Lock()
+	defer gatewayMu.Unlock()
+	faultGateway = &payment.FaultGateway{Gateway: gateway}
+	gateway = faultGateway
+}
+
+// paymentFaults returns the fault injector, or writes 404 when fault injection is disabled.
+func paymentFaults(w http.ResponseWriter) (*payment.FaultGateway, bool) {
+	gatewayMu.RLock()
+	defer gatewayMu.RUnlock()
+	if faultGateway == nil {
+		writeError(w, http.StatusNotFound, "payment fault injection is disabled")
+		return nil, false
+	}
+	return faultGateway, true
+}
+
+// writeFaults writes the injector's configuration (with the unused part of the sequence) and stats.
+func writeFaults(w http.ResponseWriter, g *payment.FaultGateway) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"config": g.Config(), "stats": g.Stats()})
+}
+
+// GET /api/test/payment-faults — the injected faults and how many charges they affected (test environments only)
+func GetPaymentFaultsHandler(w http.ResponseWriter, r *http.Request) { // get payment fault injection
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	g, ok := paymentFaults(w)
+	if !ok {
+		return
+	}
+	writeFaults(w, g)
+}
+
+// PUT /api/test/payment-faults — replace the injected faults, e.g. {"sequence": ["timeout", "timeout", "success"]}
+// so a purchase succeeds on its third attempt, or {"errorRate": 20, "error": "unavailable"} (test environments only)
+func PutPaymentFaultsHandler(w http.ResponseWriter, r *http.Request) { // configure payment fault injection
+	if r.Method != http.MethodPut {
+		return
+	}
+	allowCORS(w)
+	g, ok := paymentFaults(w)
+	if !ok {
+		return
+	}
+	var cfg payment.FaultConfig
+	if json.NewDecoder(r.Body).Decode(&cfg) != nil {
+		writeValidationError(w, "invalid fault configuration")
+		return
+	}
+	if err := g.Configure(cfg); err != nil {
+		writeValidationError(w, err.Error())
+		return
+	}
+	writeFaults(w, g)
+}
+
+// DELETE /api/test/payment-faults — stop injecting faults (test environments only)
+func DeletePaymentFaultsHandler(w http.ResponseWriter, r *http.Request) { // clear payment fault injection
+	if r.Method != http.MethodDelete {
+		return
+	}
+	allowCORS(w)
+	g, ok := paymentFaults(w)
+	if !ok {
+		return
+	}
+	g.Configure(payment.FaultConfig{})
+	writeFaults(w, g)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"SnakeGame/payment"
+)
+
+func TestPaymentFaults_SucceedsOnThirdAttempt(t *testing.T) {
+	resetPlayer(t)
+	if rec := serve(GetPaymentFaultsHandler, http.MethodGet, "/api/test/payment-faults", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("fault injection disabled: want 404, got %d", rec.Code)
+	}
+	stub := &payment.StubGateway{}
+	SetPaymentGateway(stub)
+	EnablePaymentFaults()
+	t.Cleanup(func() {
+		gatewayMu.Lock()
+		faultGateway = nil
+		gatewayMu.Unlock()
+		SetPaymentGateway(&payment.StubGateway{})
+	})
+
+	rec := serve(PutPaymentFaultsHandler, http.MethodPut, "/api/test/payment-faults", `{"sequence":["timeout","timeout","success"]}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("configure: %d %s", rec.Code, rec.Body)
+	}
+	buy := withPath(PostCoinPackPurchaseHandler, "coins_500")
+	if rec := serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("purchase: want success on the third attempt, got %d %s", rec.Code, rec.Body)
+	}
+	if s := faultGateway.Stats(); s.Calls != 3 || s.Injected[payment.FaultTimeout] != 2 {
+		t.Errorf("stats: %+v", s)
+	}
+	if n := len(stub.Charges()); n != 1 || player.Balance != 700 {
+		t.Errorf("want one charge and 500 coins credited; charges %d, balance %d", n, player.Balance)
+	}
+
+	if rec := serve(PutPaymentFaultsHandler, http.MethodPut, "/api/test/payment-faults", `{"errorRate":150}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid config: want 400, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"SnakeGame/store"
+)
+
+// writeGuestCart sends a guest cart (items + total).
+func writeGuestCart(w http.ResponseWriter, status int, guestID string) { // write a guest cart response
+	items, total, err := store.GuestCart(guestID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	lines, _ := cartLines(items)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"guestId": guestID, "items": lines, "total": total})
+}
+
+// POST /api/guest/carts — start a cart for a visitor who has not signed in
+func PostGuestCartHandler(w http.ResponseWriter, r *http.Request) { // create a guest cart
+	if r.Method != http.MethodPost {
+		return
+	}
+	allowCORS(w)
+	writeGuestCart(w, http.StatusCreated, store.NewGuestCart())
+}
+
+// GET /api/guest/carts/{guestId} — view a guest cart
+func GetGuestCartHandler(w http.ResponseWriter, r *http.Request) { // view a guest cart
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	writeGuestCart(w, http.StatusOK, r.PathValue("guestId"))
+}
+
+// POST /api/guest/carts/{guestId}/items — add an item to a guest cart
+func PostGuestCartItemsHandler(w http.ResponseWriter, r *http.Request) { // add an item to a guest cart
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req struct { // request body for adding an item to a guest cart
+		ItemID string `json:"itemId"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.ItemID == "" {
+		writeValidationError(w, "invalid itemId")
+		return
+	}
+	allowCORS(w)
+	guestID := r.PathValue("guestId")
+	if err := store.AddToGuestCart(guestID, req.ItemID); err != nil {
+		if errors.Is(err, store.ErrGuestCartNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeValidationError(w, err.Error())
+		return
+	}
+	writeGuestCart(w, http.StatusCreated, guestID)
+}
+
+// POST /api/user/cart/merge — called on sign-in: merge a guest cart into the account cart and discard it
+func MergeGuestCartHandler(w http.ResponseWriter, r *http.Request) { // merge a guest cart into the account cart
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req struct { // request body for merging a guest cart
+		GuestID string `json:"guestId"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.GuestID == "" {
+		writeValidationError(w, "invalid guestId")
+		return
+	}
+	allowCORS(w)
+	report, err := store.MergeGuestCart(req.GuestID, ownedSkins())
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	items, total, version := store.GetCartWithVersion()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", cartETag(version))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"merged":  report.Merged,
+		"dropped": report.Dropped,
+		"cart":    cartResponse(items, total),
+	})
+}
@@ -1,33 +1,43 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"SnakeGame/models"
-	"SnakeGame/payment"
-	"SnakeGame/retry"
 	"SnakeGame/store"
 )
 
 const coinsPerScore = 2 // coins per 10 points
 const idempotencyTTL = 24 * time.Hour
 
+// playerState is one player's wallet and inventory.
+type playerState struct {
+	ID           string   `json:"ID"` // player id (used for per-player limits such as single-use coupons)
+	Name         string   `json:"Name"` // display name (shown on gifts the player sends)
+	Balance      int      `json:"Balance"` // player's balance in coins
+	OwnedSkins   []string `json:"OwnedSkins"` // list of owned skins
+	EquippedSkin string   `json:"EquippedSkin"` // currently equipped skin
+	ExtraLives   int      `json:"ExtraLives"` // number of extra lives
+	Gifts        []gift   `json:"Gifts"` // gifts received from other players (newest last)
+}
+
 var (
-	playerMu sync.RWMutex // protects player field
-	player   = struct {
-		Balance      int      `json:"Balance"` // player's balance in coins
-		OwnedSkins   []string `json:"OwnedSkins"` // list of owned skins
-		EquippedSkin string   `json:"EquippedSkin"` // currently equipped skin
-		ExtraLives   int      `json:"ExtraLives"` // number of extra lives
-	}{
+	playerMu sync.RWMutex // protects player, players and every playerState
+	player   = &playerState{
+		ID:           "player1", // the local player
+		Name:         "Player 1", // initial display name
 		Balance:      200, // initial balance
 		OwnedSkins:   []string{"default"}, // initial owned skins
 		EquippedSkin: "default", // initial equipped skin
 		ExtraLives:   0, // initial extra lives
+		Gifts:        []gift{}, // no gifts yet
 	}
 	idempotencyMu    sync.RWMutex // protects idempotencyCache field		
 	idempotencyCache = make(map[string]*idempotencyEntry) // map of idempotency keys to entries
@@ -37,6 +47,7 @@ type idempotencyEntry struct {
 	StatusCode int // status code of the response
 	Body       []byte // body of the response
 	CreatedAt  time.Time // time the entry was created
+	inFlight   chan struct{} // while a request holds the key: closed when it stores a response or releases the key
 }
 
 func getIdempotency(key string) (statusCode int, body []byte, ok bool) { // get idempotency entry for a key
@@ -46,8 +57,8 @@ func getIdempotency(key string) (statusCode int, body []byte, ok bool) { // get
 	idempotencyMu.Lock()
 	defer idempotencyMu.Unlock()
 	ent, exists := idempotencyCache[key]
-	if !exists || ent == nil {
-		return 0, nil, false // entry not found or nil
+	if !exists || ent == nil || ent.inFlight != nil {
+		return 0, nil, false // entry not found, nil or still in flight
 	}
 	if time.Since(ent.CreatedAt) > idempotencyTTL {
 		delete(idempotencyCache, key)
@@ -66,6 +77,9 @@ func setIdempotency(key string, statusCode int, body []byte) { // set idempotenc
 	copy(bodyCopy, body)
 	idempotencyMu.Lock()
 	defer idempotencyMu.Unlock()
+	if ent, ok := idempotencyCache[key]; ok && ent.inFlight != nil {
+		close(ent.inFlight) // wake requests waiting for this response
+	}
 	idempotencyCache[key] = &idempotencyEntry{
 		StatusCode: statusCode,
 		Body:       bodyCopy,
@@ -73,16 +87,61 @@ func setIdempotency(key string, statusCode int, body []byte) { // set idempotenc
 	}
 }
 
+// claimIdempotency returns the cached response for key, or reserves the key for the calling request.
+// While another request holds the key it waits for that request to finish, then replays its response
+// (or claims the key if the other request released it). The holder must end with setIdempotency or
+// releaseIdempotency. An empty key is never claimed.
+func claimIdempotency(key string) (statusCode int, body []byte, cached bool) {
+	if key == "" {
+		return 0, nil, false
+	}
+	for {
+		idempotencyMu.Lock()
+		ent, exists := idempotencyCache[key]
+		if exists && ent.inFlight != nil {
+			wait := ent.inFlight
+			idempotencyMu.Unlock()
+			<-wait
+			continue
+		}
+		if exists && time.Since(ent.CreatedAt) <= idempotencyTTL {
+			bodyCopy := append([]byte(nil), ent.Body...)
+			idempotencyMu.Unlock()
+			return ent.StatusCode, bodyCopy, true
+		}
+		idempotencyCache[key] = &idempotencyEntry{CreatedAt: time.Now(), inFlight: make(chan struct{})}
+		idempotencyMu.Unlock()
+		return 0, nil, false
+	}
+}
+
+// releaseIdempotency gives up a key claimed by claimIdempotency without caching a response, so the
+// next request with the key runs again. It does nothing once a response has been stored.
+func releaseIdempotency(key string) {
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+	if ent, ok := idempotencyCache[key]; ok && ent.inFlight != nil {
+		close(ent.inFlight)
+		delete(idempotencyCache, key)
+	}
+}
+
 func allowCORS(w http.ResponseWriter) { // allow CORS for all methods
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Idempotency-Key, X-Simulate-Payment-Timeout")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Idempotency-Key, If-Match, X-Player-ID, X-Simulate-Payment-Timeout")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
 }
 
 // writeValidationError sends a 400 Bad Request with a consistent JSON error body.
 func writeValidationError(w http.ResponseWriter, message string) { // write a validation error
+	writeError(w, http.StatusBadRequest, message)
+}
+
+// writeError sends the given status with a consistent JSON error body.
+func writeError(w http.ResponseWriter, status int, message string) { // write an error with a status code
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]string{"error": message})
 }
 
@@ -112,11 +171,15 @@ func EarnCoinsHandler(w http.ResponseWriter, r *http.Request) { // earn coins
 	w.Header().Set("Content-Type", "application/json")
 	playerMu.Lock()
 	earned := (req.Score / 10) * coinsPerScore
+	previous := player.Balance
 	player.Balance += earned
+	balance := player.Balance
+	publishBalance(player)
 	playerMu.Unlock()
+	store.CheckWishlistAffordability(previous, balance) // notify about wishlisted items that became affordable
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"earned":  earned,
-		"balance": player.Balance,
+		"balance": balance,
 	})
 }
 
@@ -138,6 +201,7 @@ func EquipHandler(w http.ResponseWriter, r *http.Request) { // equip a skin
 	for _, s := range player.OwnedSkins {
 		if s == req.SkinID {
 			player.EquippedSkin = req.SkinID
+			publishSkins(player)
 			json.NewEncoder(w).Encode(map[string]string{"equipped": req.SkinID})
 			return
 		}
@@ -145,19 +209,79 @@ func EquipHandler(w http.ResponseWriter, r *http.Request) { // equip a skin
 	writeValidationError(w, "skin not owned")
 }
 
-// cartResponse builds the common cart JSON (items + total).
+// cartResponse builds the common cart JSON (items + total). Each line carries its list price and
+// per-unit sale discount; originalTotal and discount summarize what the sales save on the whole cart.
+// When a coupon is attached, total is the subtotal minus the coupon discount, figured over the lines
+// checkout would charge (see priceCheckout), so callers must not hold playerMu. expiresAt is when an
+// untouched cart will be discarded.
 func cartResponse(items []models.CartItem, total int) map[string]interface{} { // build the cart response
+	itemsResp, originalTotal := cartLines(items)
+	resp := map[string]interface{}{"items": itemsResp, "subtotal": total, "total": total, "originalTotal": originalTotal, "discount": originalTotal - total}
+	if c, ok := store.CartCoupon(); ok {
+		playerMu.RLock()
+		couponDiscount := priceCheckout(player, player, items, &c).CouponDiscount
+		playerMu.RUnlock()
+		resp["coupon"] = map[string]interface{}{"code": c.Code, "discount": couponDiscount}
+		resp["total"] = total - couponDiscount
+	}
+	if expiresAt, ok := store.CartExpiresAt(); ok {
+		resp["expiresAt"] = expiresAt
+	}
+	return resp
+}
+
+// cartLines builds the JSON lines of a cart and the sum of list prices.
+func cartLines(items []models.CartItem) ([]map[string]interface{}, int) {
 	itemsResp := make([]map[string]interface{}, len(items))
+	var originalTotal int
 	for i, it := range items {
 		itemsResp[i] = map[string]interface{}{
-			"id":       it.ID,
-			"itemId":   it.ItemID,
-			"name":     it.Name,
-			"price":    it.Price,
-			"quantity": it.Quantity,
+			"id":            it.ID,
+			"itemId":        it.ItemID,
+			"name":          it.Name,
+			"price":         it.Price,
+			"originalPrice": it.OriginalPrice,
+			"discount":      it.OriginalPrice - it.Price,
+			"quantity":      it.Quantity,
 		}
+		originalTotal += it.OriginalPrice * it.Quantity
 	}
-	return map[string]interface{}{"items": itemsResp, "total": total}
+	return itemsResp, originalTotal
+}
+
+// cartETag formats a cart version as a strong ETag.
+func cartETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// ifMatchVersion parses the If-Match header into a cart version. present is false when the header
+// is absent or "*" (no precondition); ok is false when it is not an ETag this server issued.
+func ifMatchVersion(r *http.Request) (version uint64, present bool, ok bool) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" || h == "*" {
+		return 0, false, true
+	}
+	h = strings.Trim(strings.TrimPrefix(h, "W/"), `"`)
+	v, err := strconv.ParseUint(h, 10, 64)
+	if err != nil {
+		return 0, true, false
+	}
+	return v, true, true
+}
+
+// writeCart sends the current cart with its version as the ETag header.
+func writeCart(w http.ResponseWriter, status int) { // write the cart response with an ETag
+	items, total, version := store.GetCartWithVersion()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", cartETag(version))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(cartResponse(items, total))
+}
+
+// writePreconditionFailed sends 412 with the current cart ETag so the client can refresh and retry.
+func writePreconditionFailed(w http.ResponseWriter) { // write a 412 for a stale If-Match
+	w.Header().Set("ETag", cartETag(store.CartVersion()))
+	writeError(w, http.StatusPreconditionFailed, store.ErrVersionMismatch.Error())
 }
 
 // POST /api/user/cart/items — add an item to the cart
@@ -174,13 +298,11 @@ func PostCartItemsHandler(w http.ResponseWriter, r *http.Request) { // add an it
 	}
 	allowCORS(w)
 	w.Header().Set("Content-Type", "application/json")
-	if err := store.AddToCart(req.ItemID); err != nil {
+	if err := store.AddToCart(req.ItemID, ownedSkins()); err != nil {
 		writeValidationError(w, err.Error())
 		return
 	}
-	items, total := store.GetCart()
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(cartResponse(items, total))
+	writeCart(w, http.StatusCreated)
 }
 
 // GET /api/user/cart — view the contents of the cart
@@ -190,8 +312,7 @@ func GetCartHandler(w http.ResponseWriter, r *http.Request) { // view the conten
 	}
 	allowCORS(w)
 	w.Header().Set("Content-Type", "application/json")
-	items, total := store.GetCart()
-	json.NewEncoder(w).Encode(cartResponse(items, total))
+	writeCart(w, http.StatusOK)
 }
 
 // PATCH /api/user/cart/items/:id — update an item (e.g. change quantity)
@@ -208,7 +329,22 @@ func PatchCartItemHandler(w http.ResponseWriter, r *http.Request, id string) { /
 	}
 	allowCORS(w)
 	w.Header().Set("Content-Type", "application/json")
-	if err := store.UpdateCartItem(id, *req.Quantity); err != nil {
+	expected, conditional, valid := ifMatchVersion(r)
+	if !valid {
+		writePreconditionFailed(w)
+		return
+	}
+	var err error
+	if conditional {
+		err = store.UpdateCartItemIfMatch(id, *req.Quantity, expected)
+	} else {
+		err = store.UpdateCartItem(id, *req.Quantity)
+	}
+	if err != nil {
+		if err == store.ErrVersionMismatch {
+			writePreconditionFailed(w)
+			return
+		}
 		if err == store.ErrCartItemNotFound {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusNotFound)
@@ -218,8 +354,7 @@ func PatchCartItemHandler(w http.ResponseWriter, r *http.Request, id string) { /
 		writeValidationError(w, err.Error())
 		return
 	}
-	items, total := store.GetCart()
-	json.NewEncoder(w).Encode(cartResponse(items, total))
+	writeCart(w, http.StatusOK)
 }
 
 // DELETE /api/user/cart/items/:id — remove an item from the cart
@@ -229,12 +364,25 @@ func DeleteCartItemHandler(w http.ResponseWriter, r *http.Request, id string) {
 	}
 	allowCORS(w)
 	w.Header().Set("Content-Type", "application/json")
-	if !store.RemoveCartItemByID(id) {
+	expected, conditional, valid := ifMatchVersion(r)
+	if !valid {
+		writePreconditionFailed(w)
+		return
+	}
+	if conditional {
+		if err := store.RemoveCartItemByIDIfMatch(id, expected); err != nil {
+			if err == store.ErrVersionMismatch {
+				writePreconditionFailed(w)
+				return
+			}
+			http.Error(w, `{"error":"cart item not found"}`, http.StatusNotFound)
+			return
+		}
+	} else if !store.RemoveCartItemByID(id) {
 		http.Error(w, `{"error":"cart item not found"}`, http.StatusNotFound)
 		return
 	}
-	items, total := store.GetCart()
-	json.NewEncoder(w).Encode(cartResponse(items, total))
+	writeCart(w, http.StatusOK)
 }
 
 // CartItemsIDHandler routes PATCH and DELETE by path /api/user/cart/items/<id>
@@ -271,12 +419,11 @@ func CartHandler(w http.ResponseWriter, r *http.Request) { // legacy handlers fo
 	}
 	allowCORS(w)
 	w.Header().Set("Content-Type", "application/json")
-	if err := store.AddToCart(req.ItemID); err != nil {
+	if err := store.AddToCart(req.ItemID, ownedSkins()); err != nil {
 		writeValidationError(w, err.Error())
 		return
 	}
-	items, total := store.GetCart()
-	json.NewEncoder(w).Encode(cartResponse(items, total))
+	writeCart(w, http.StatusOK)
 }
 
 func RemoveCartItemHandler(w http.ResponseWriter, r *http.Request) { // remove an item from the cart
@@ -293,46 +440,175 @@ func RemoveCartItemHandler(w http.ResponseWriter, r *http.Request) { // remove a
 	allowCORS(w)
 	w.Header().Set("Content-Type", "application/json")
 	store.RemoveCartItem(req.ItemID)
-	items, total := store.GetCart()
-	json.NewEncoder(w).Encode(cartResponse(items, total))
+	writeCart(w, http.StatusOK)
 }
 
-// doCheckout runs the checkout logic and returns the HTTP status code and response body.
-// It validates first (400 on empty cart or insufficient balance), then calls the payment
-// gateway with retry (exponential backoff). Stop conditions: success, non-retryable error,
-// max attempts, or context cancelled. Used so the response can be cached for idempotency.
-func doCheckout(ctx context.Context, gw payment.Gateway, idempotencyKey string) (statusCode int, body []byte) { // run the checkout logic and return the HTTP status code and response body
+// ownedSkins returns a copy of the player's owned skins (for ownership-aware cart validation).
+func ownedSkins() []string {
+	playerMu.RLock()
+	defer playerMu.RUnlock()
+	return append([]string(nil), player.OwnedSkins...)
+}
+
+// ownsSkin reports whether p already owns the skin. Callers hold playerMu.
+func (p *playerState) ownsSkin(skinID string) bool {
+	for _, s := range p.OwnedSkins {
+		if s == skinID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkoutOptions are the per-request inputs to doCheckout.
+type checkoutOptions struct {
+	idempotencyKey string
+	ifMatch        *uint64 // cart version from If-Match; nil when the client sent no precondition
+	recipientID    string  // gift recipient; "" delivers to the buying player
+	giftMessage    string  // optional message shown on the gift record
+	purchase       *models.CartItem // instant purchase of one item instead of the cart; nil for a cart checkout
+}
+
+// checkoutHolds are the coupon redemption and stock units reserved by one checkout.
+type checkoutHolds struct {
+	playerID string
+	coupon   string         // reserved coupon code ("" for none)
+	stock    map[string]int // reserved units per limited item
+}
+
+// release gives back everything held after a failed checkout.
+func (h *checkoutHolds) release() {
+	if h.coupon != "" {
+		store.ReleaseCoupon(h.coupon, h.playerID)
+	}
+	models.ReleaseStock(h.stock)
+}
+
+// commit counts the coupon redemption and sells the reserved stock after a completed purchase.
+func (h *checkoutHolds) commit() {
+	if h.coupon != "" {
+		store.CommitCoupon(h.coupon, h.playerID)
+	}
+	models.CommitStock(h.stock)
+}
+
+// checkoutCart reprices the cart and reads it for checkout. A non-nil body is the response that ends
+// the checkout: 409 when prices changed, 412 when opts.ifMatch is stale, or Fail for an empty cart.
+func checkoutCart(opts checkoutOptions) (items []models.CartItem, cartVersion uint64, statusCode int, body []byte) {
 	statusCode = http.StatusOK
-	items, _ := store.GetCart()
+	// Revalidate against the current catalog: never charge a stale price or an item that is gone.
+	if changed, removed := store.RepriceCart(); len(changed) > 0 || len(removed) > 0 {
+		if changed == nil {
+			changed = []store.PriceChange{}
+		}
+		if removed == nil {
+			removed = []models.CartItem{}
+		}
+		items, total := store.GetCart()
+		statusCode = http.StatusConflict
+		out := map[string]interface{}{ // response body for a cart that no longer matches the catalog
+			"Status":       "Fail",
+			"Message":      "Prices changed since items were added. Review the updated cart and check out again.",
+			"PriceChanges": changed,
+			"RemovedItems": removed,
+			"Cart":         cartResponse(items, total),
+		}
+		body, _ = json.Marshal(out)
+		return nil, 0, statusCode, body
+	}
+	items, _, cartVersion = store.GetCartWithVersion()
+	if opts.ifMatch != nil && *opts.ifMatch != cartVersion {
+		items, total := store.GetCart()
+		statusCode = http.StatusPreconditionFailed
+		out := map[string]interface{}{ // response body for a cart that changed since the client read it
+			"Status":  "Fail",
+			"Message": "Cart has been modified. Review the cart and check out again.",
+			"Cart":    cartResponse(items, total),
+		}
+		body, _ = json.Marshal(out)
+		return nil, 0, statusCode, body
+	}
 	if len(items) == 0 {
 		out := map[string]interface{}{ // response body for empty cart
 			"Status":  "Fail",
 			"Message": "Cart is empty",
 		}
 		body, _ = json.Marshal(out)
+		return nil, 0, statusCode, body
+	}
+	return items, cartVersion, statusCode, nil
+}
+
+// doCheckout runs the checkout logic and returns the HTTP status code and response body.
+// Cart lines are first repriced against the catalog; any change returns 409 with old and new
+// prices per line and the updated cart, which the player confirms with a new checkout request.
+// When opts.ifMatch is set and the cart version differs, 412 is returned and nothing is charged.
+// It validates first (400 on empty cart, unusable coupon, insufficient balance or sold-out stock), then
+// debits the coin balance and delivers the items; the payment gateway is never involved (see
+// PostCoinPackPurchaseHandler). Returns the response so it can be cached for idempotency.
+// With opts.purchase set it buys that single line instead: the cart, its coupon and its version are
+// not involved.
+func doCheckout(opts checkoutOptions) (statusCode int, body []byte) { // run the checkout logic and return the HTTP status code and response body
+	statusCode = http.StatusOK
+	var items []models.CartItem // lines to price and deliver
+	var cartVersion uint64      // cart version the lines were read at (cart checkouts only)
+	if opts.purchase != nil {
+		items = []models.CartItem{*opts.purchase} // instant purchase: the cart is neither read nor changed
+	} else if items, cartVersion, statusCode, body = checkoutCart(opts); body != nil {
 		return statusCode, body
 	}
 
+	// Hold the cart's coupon and any limited stock for the duration of the checkout; they are only
+	// counted when the purchase completes and are released on every failure path.
+	holds := &checkoutHolds{playerID: player.ID}
+	var applied *store.Coupon // coupon discounting this checkout (nil for none)
+	if coupon, hasCoupon := store.CartCoupon(); hasCoupon && opts.purchase == nil {
+		if _, err := store.ReserveCoupon(coupon.Code, player.ID); err != nil {
+			out := map[string]interface{}{ // response body for a coupon that can no longer be used
+				"Status":  "Fail",
+				"Message": "Coupon " + coupon.Code + " cannot be used: " + err.Error(),
+			}
+			body, _ = json.Marshal(out)
+			return statusCode, body
+		}
+		holds.coupon = coupon.Code
+		applied = &coupon
+	}
+
 	playerMu.Lock()
-	// Compute the amount we actually charge: skip price for skins already owned
-	var chargeTotal int
-	for _, it := range items {
-		if models.IsSkin(it.ItemID) {
-			alreadyOwned := false
-			for _, s := range player.OwnedSkins {
-				if s == it.ItemID {
-					alreadyOwned = true
-					break
-				}
+	owner := player // player receiving the items
+	if opts.recipientID != "" {
+		recipient, ok := lookupPlayer(opts.recipientID)
+		if !ok {
+			playerMu.Unlock()
+			holds.release()
+			out := map[string]interface{}{ // response body for an unknown gift recipient
+				"Status":  "Fail",
+				"Message": "Gift recipient not found",
 			}
-			if alreadyOwned {
-				continue
+			body, _ = json.Marshal(out)
+			return statusCode, body
+		}
+		// A gift skin the recipient already has is an error, not a silent skip: the sender chose it for them.
+		if owned := giftConflicts(recipient, items); len(owned) > 0 {
+			playerMu.Unlock()
+			holds.release()
+			out := map[string]interface{}{ // response body for a gift the recipient already owns
+				"Status":       "Fail",
+				"Message":      recipient.Name + " already owns " + strings.Join(owned, ", "),
+				"AlreadyOwned": owned,
 			}
+			body, _ = json.Marshal(out)
+			return statusCode, body
 		}
-		chargeTotal += it.Price * it.Quantity
+		owner = recipient
 	}
+	// Compute the amount we actually charge: skip owned skins, apply the coupon (shared with the quote endpoint)
+	q := priceCheckout(player, owner, items, applied)
+	chargeTotal, couponDiscount := q.Total, q.CouponDiscount
 	if player.Balance < chargeTotal {
 		playerMu.Unlock()
+		holds.release()
 		out := map[string]interface{}{ // response body for insufficient balance
 			"Status":  "Fail",
 			"Message": "Not enough coins",
@@ -341,67 +617,79 @@ func doCheckout(ctx context.Context, gw payment.Gateway, idempotencyKey string)
 		body, _ = json.Marshal(out)
 		return statusCode, body
 	}
-	playerMu.Unlock()
-
-	// Call payment gateway with retry (exponential backoff). Stop conditions: success,
-	// non-retryable error, max attempts, or context cancelled.
-	cfg := retry.DefaultConfig() // retry configuration	
-	cfg.MaxAttempts = 5 // maximum number of attempts
-	cfg.InitialDelay = 100 * time.Millisecond // initial delay
-	cfg.MaxDelay = 5 * time.Second // maximum delay
-	err := retry.Do(ctx, cfg, func() error { // call the payment gateway with retry
-		return gw.Charge(ctx, chargeTotal, idempotencyKey)
-	})
-	if err != nil {
-		// Retries exhausted or non-retryable
-		statusCode = http.StatusServiceUnavailable
-		out := map[string]interface{}{ // response body for payment temporarily unavailable
+	stockQty := make(map[string]int) // units of limited items this checkout will deliver
+	for _, it := range q.charged {
+		stockQty[it.ItemID] += it.Quantity
+	}
+	if err := models.ReserveStock(stockQty); err != nil {
+		playerMu.Unlock()
+		holds.release()
+		out := map[string]interface{}{ // response body for a sold-out limited item
 			"Status":  "Fail",
-			"Message": "Payment temporarily unavailable. Please try again.",
+			"Message": err.Error(),
 		}
 		body, _ = json.Marshal(out)
 		return statusCode, body
 	}
+	holds.stock = stockQty
 
-	// Gateway succeeded: apply balance deduction and purchases
-	playerMu.Lock()
+	// Skins and lives are paid in coins only: debit the wallet in the same critical section as the
+	// balance check. Real money goes through the gateway only when buying coin packs.
 	defer playerMu.Unlock()
 
 	player.Balance -= chargeTotal
 
 	var lastNewSkin string // last new skin added to the cart
-	for _, it := range items {
-		if models.IsSkin(it.ItemID) {
-			alreadyOwned := false
-			for _, s := range player.OwnedSkins {
-				if s == it.ItemID {
-					alreadyOwned = true
-					break
-				}
-			}
-			if !alreadyOwned {
-				player.OwnedSkins = append(player.OwnedSkins, it.ItemID)
-				lastNewSkin = it.ItemID
-			}
+	gotLives := false // whether the purchase delivered extra lives
+	for _, it := range q.charged {
+		if models.IsSkin(it.ItemID) && !owner.ownsSkin(it.ItemID) {
+			owner.OwnedSkins = append(owner.OwnedSkins, it.ItemID)
+			lastNewSkin = it.ItemID
 		}
 		if it.ItemID == "extra_life" {
-			player.ExtraLives += it.Quantity
+			owner.ExtraLives += it.Quantity
+			gotLives = true
 		}
 	}
-	if lastNewSkin != "" {
+	var sent *gift // gift record delivered to the recipient (nil for a normal purchase)
+	if owner != player {
+		g := recordGift(player, owner, q.charged, opts.giftMessage)
+		sent = &g
+	} else if lastNewSkin != "" {
 		player.EquippedSkin = lastNewSkin
 	}
+	holds.commit()
+	publishBalance(player)
+	if lastNewSkin != "" {
+		publishSkins(owner)
+	}
+	if gotLives {
+		publishLives(owner)
+	}
 
-	store.ClearCart()
+	if opts.purchase == nil {
+		lineIDs := make([]string, len(items))
+		for i, it := range items {
+			lineIDs[i] = it.ID
+		}
+		store.ClearPurchased(cartVersion, lineIDs)
+	}
 
 	out := map[string]interface{}{ // response body for successful checkout
 		"Status":       "Success",
 		"Message":      "Purchase complete!",
+		"Charged":      chargeTotal,
+		"Discount":     couponDiscount,
 		"Balance":      player.Balance,
 		"OwnedSkins":   player.OwnedSkins,
 		"EquippedSkin": player.EquippedSkin,
 		"ExtraLives":   player.ExtraLives,
 	}
+	if sent != nil {
+		out["Message"] = "Gift sent to " + owner.Name + "!"
+		out["Gift"] = sent
+		out["RecipientID"] = owner.ID
+	}
 	body, _ = json.Marshal(out)
 	return statusCode, body
 }
@@ -410,33 +698,76 @@ func doCheckout(ctx context.Context, gw payment.Gateway, idempotencyKey string)
 // already own (skins already in OwnedSkins are skipped). Prevents deducting coins
 // for duplicate skins. Uses Idempotency-Key header: repeated requests with the
 // same key within 24 hours receive the cached response without re-processing.
-// Set header X-Simulate-Payment-Timeout: true to simulate gateway timeout (for testing retry).
-func CheckoutHandler(w http.ResponseWriter, r *http.Request) { // process the cart: only charges for items the player does not already own (skins already in OwnedSkins are skipped). Prevents deducting coins for duplicate skins. Uses Idempotency-Key header: repeated requests with the same key within 24 hours receive the cached response without re-processing. Set header X-Simulate-Payment-Timeout: true to simulate gateway timeout (for testing retry).
+// If-Match with the cart ETag makes the checkout conditional: 412 if the cart changed.
+// Body {"recipientId": "...", "message": "..."} sends the items as a gift: the sender is charged,
+// the recipient receives them, and skins the recipient already owns fail the checkout.
+// Checkout debits coins only; real-money charges happen when buying coin packs.
+func CheckoutHandler(w http.ResponseWriter, r *http.Request) { // process the cart: only charges for items the player does not already own (skins already in OwnedSkins are skipped). Prevents deducting coins for duplicate skins. Uses Idempotency-Key header: repeated requests with the same key within 24 hours receive the cached response without re-processing.
 	if r.Method != http.MethodPost {
 		return
 	}
 	allowCORS(w)
 	w.Header().Set("Content-Type", "application/json")
 
+	// Claim the key first so a concurrent retry waits for this request and replays its response.
 	key := r.Header.Get("Idempotency-Key") // idempotency key
-	if key != "" {
-		if status, cached, ok := getIdempotency(key); ok { // check if the idempotency key is valid	
-			w.WriteHeader(status)
-			w.Write(cached)
-			return
-		}
+	if status, cached, ok := claimIdempotency(key); ok {
+		w.WriteHeader(status)
+		w.Write(cached)
+		return
 	}
+	defer releaseIdempotency(key) // validation errors are not cached and leave the key free
 
-	gw := &payment.StubGateway{
-		SimulateTimeout: r.Header.Get("X-Simulate-Payment-Timeout") == "true",
+	var req struct { // optional request body for gift purchases
+		RecipientID string `json:"recipientId"`
+		Message     string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeValidationError(w, "invalid request body")
+		return
+	}
+	if req.RecipientID == player.ID {
+		writeValidationError(w, "cannot send a gift to yourself")
+		return
+	}
+	if req.Message != "" && req.RecipientID == "" {
+		writeValidationError(w, "message requires a recipientId")
+		return
+	}
+	if utf8.RuneCountInString(req.Message) > maxGiftMessage {
+		writeValidationError(w, "gift message is too long")
+		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
 
-	status, body := doCheckout(ctx, gw, key)
-	if key != "" {
-		setIdempotency(key, status, body)
+	opts := checkoutOptions{idempotencyKey: key, recipientID: req.RecipientID, giftMessage: req.Message}
+	expected, conditional, valid := ifMatchVersion(r)
+	if !valid {
+		writePreconditionFailed(w)
+		return
+	}
+	if conditional {
+		opts.ifMatch = &expected
 	}
+
+	status, body := runCheckout(opts)
+	w.Header().Set("ETag", cartETag(store.CartVersion()))
 	w.WriteHeader(status)
 	w.Write(body)
 }
+
+// runCheckout runs doCheckout and settles opts.idempotencyKey, which the caller has claimed with
+// claimIdempotency, so cart checkout and instant purchase share one path. Only responses that
+// committed or failed for good are cached: a price-change 409 and a stale If-Match 412 commit nothing
+// and ask the player to review the cart, so they release the key for the retry after that review.
+func runCheckout(opts checkoutOptions) (status int, body []byte) {
+	status, body = doCheckout(opts)
+	if opts.idempotencyKey == "" {
+		return status, body
+	}
+	if status == http.StatusConflict || status == http.StatusPreconditionFailed {
+		releaseIdempotency(opts.idempotencyKey)
+	} else {
+		setIdempotency(opts.idempotencyKey, status, body)
+	}
+	return status, body
+}
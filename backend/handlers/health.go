@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SnakeGame/breaker"
+)
+
+// GET /api/health — server health and the payment gateway's circuit breaker state. Status is "ok",
+// or "degraded" while the breaker is not closed (coin pack purchases fail fast or are being probed).
+func GetHealthHandler(w http.ResponseWriter, r *http.Request) { // report health
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	gw := gatewayBreaker().Snapshot()
+	status := "ok"
+	if gw.State != breaker.StateClosed {
+		status = "degraded"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         status,
+		"paymentGateway": gw,
+	})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"SnakeGame/breaker"
+	"SnakeGame/orders"
+	"SnakeGame/payment"
+)
+
+func TestCoinPack_OpenBreakerFailsFast(t *testing.T) {
+	resetPlayer(t)
+	down := &payment.StubGateway{SimulateTimeout: true}
+	SetPaymentGateway(down)
+	SetPaymentBreaker(breaker.Config{FailureThreshold: 2, Cooldown: time.Minute})
+	t.Cleanup(func() {
+		SetPaymentGateway(&payment.StubGateway{})
+		SetPaymentBreaker(breaker.Config{})
+	})
+	buy := withPath(PostCoinPackPurchaseHandler, "coins_500")
+
+	// The breaker opens after two timeouts and stops the retries instead of running all five.
+	rec := serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("gateway down: want 503, got %d %s", rec.Code, rec.Body)
+	}
+
+	before := len(orders.List())
+	start := time.Now()
+	rec = serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", map[string]string{"Idempotency-Key": "pack-breaker-1"})
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("open breaker: want 503 with Retry-After 60, got %d %q %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("open breaker must fail fast, took %s", elapsed)
+	}
+	if len(orders.List()) != before {
+		t.Error("a fail-fast response must not create an order")
+	}
+	if _, _, cached := getIdempotency("pack-breaker-1"); cached {
+		t.Error("a fail-fast response must not be cached under the idempotency key")
+	}
+
+	rec = serve(GetHealthHandler, http.MethodGet, "/api/health", "", nil)
+	var health struct {
+		Status         string           `json:"status"`
+		PaymentGateway breaker.Snapshot `json:"paymentGateway"`
+	}
+	json.NewDecoder(rec.Body).Decode(&health)
+	if health.Status != "degraded" || health.PaymentGateway.State != breaker.StateOpen || health.PaymentGateway.RetryAfterSeconds != 60 {
+		t.Errorf("health: %+v", health)
+	}
+	if player.Balance != 200 {
+		t.Errorf("no coins may be credited while the gateway is down; balance %d", player.Balance)
+	}
+}
+
+func TestCoinPack_BreakerOpeningMidRetryIsRetryableWithSameKey(t *testing.T) {
+	resetPlayer(t)
+	SetPaymentGateway(&countingGateway{err: &payment.ProviderError{StatusCode: http.StatusServiceUnavailable, Err: payment.ErrUnavailable}})
+	SetPaymentBreaker(breaker.Config{FailureThreshold: 1, Cooldown: time.Minute})
+	t.Cleanup(func() {
+		SetPaymentGateway(&payment.StubGateway{})
+		SetPaymentBreaker(breaker.Config{})
+	})
+	buy := withPath(PostCoinPackPurchaseHandler, "coins_500")
+	headers := map[string]string{"Idempotency-Key": "pack-breaker-mid-1"}
+
+	// The first attempt's outage opens the breaker; the retry is rejected by it.
+	rec := serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", headers)
+	var out map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusServiceUnavailable || out["Code"] != "circuit_open" || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("breaker opened mid-retry: want 503 circuit_open with Retry-After 60, got %d %q %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body)
+	}
+	if _, _, cached := getIdempotency("pack-breaker-mid-1"); cached {
+		t.Fatal("a circuit_open response must not be cached under the idempotency key")
+	}
+
+	// Once the provider recovers, the same key buys the pack.
+	SetPaymentGateway(&payment.StubGateway{})
+	SetPaymentBreaker(breaker.Config{})
+	rec = serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", headers)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retry with the same key after recovery: want 200, got %d %s", rec.Code, rec.Body)
+	}
+	if player.Balance != 700 {
+		t.Errorf("balance: want 700, got %d", player.Balance)
+	}
+}
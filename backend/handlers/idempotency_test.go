@@ -78,3 +78,29 @@ func TestSetIdempotency_EmptyKeyIgnored(t *testing.T) {
 		t.Fatal("empty key should not be stored")
 	}
 }
+
+// Test idempotency claim: a second claim of a key in flight waits and replays the stored response;
+// a released key can be claimed again.
+func TestClaimIdempotency_WaitsForHolder(t *testing.T) {
+	key := "test-key-claim"
+	if _, _, cached := claimIdempotency(key); cached {
+		t.Fatal("first claim should not find a cached response")
+	}
+	replayed := make(chan string)
+	go func() {
+		_, body, _ := claimIdempotency(key)
+		replayed <- string(body)
+	}()
+	setIdempotency(key, 200, []byte(`"done"`))
+	if body := <-replayed; body != `"done"` {
+		t.Errorf("waiting claim: want the holder's response, got %q", body)
+	}
+
+	released := "test-key-claim-released"
+	claimIdempotency(released)
+	releaseIdempotency(released)
+	if _, _, cached := claimIdempotency(released); cached {
+		t.Error("a released key must be claimable again")
+	}
+	releaseIdempotency(released)
+}
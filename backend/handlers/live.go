@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"SnakeGame/store"
+)
+
+// Live event types pushed to a player's event stream.
+const (
+	liveBalance = "balance" // {"balance": n}
+	liveSkins   = "skins"   // {"ownedSkins": [...], "equippedSkin": "..."}
+	liveLives   = "lives"   // {"extraLives": n}
+	liveCart    = "cart"    // same body as GET /api/user/cart
+)
+
+// liveBuffer is how many events a stream may fall behind before it is closed.
+const liveBuffer = 16
+
+// liveHeartbeat is how often an idle stream gets a comment line so proxies keep it open.
+const liveHeartbeat = 15 * time.Second
+
+// liveEvent is one Server-Sent Event, already encoded.
+type liveEvent struct {
+	typ  string
+	data []byte
+}
+
+// liveBroker fans events out to the open event streams of each player.
+type liveBroker struct {
+	mu   sync.Mutex                             // protects subs and closing their channels
+	subs map[string]map[chan liveEvent]struct{} // player id -> open streams
+}
+
+var live = &liveBroker{subs: map[string]map[chan liveEvent]struct{}{}}
+
+// subscribe opens a stream for playerID. Call the returned func to close it. The channel is closed
+// if the stream falls behind (see publish).
+func (b *liveBroker) subscribe(playerID string) (<-chan liveEvent, func()) {
+	ch := make(chan liveEvent, liveBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[playerID] == nil {
+		b.subs[playerID] = map[chan liveEvent]struct{}{}
+	}
+	b.subs[playerID][ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[playerID], ch) // no-op if publish already dropped it
+		if len(b.subs[playerID]) == 0 {
+			delete(b.subs, playerID)
+		}
+	}
+}
+
+// publish sends an event to every open stream of playerID. It never blocks: a stream whose buffer
+// is full is dropped and its channel closed, rather than silently missing the event, which may be
+// the last change of its kind. The client reconnects and starts again from a fresh snapshot.
+func (b *liveBroker) publish(playerID, typ string, v interface{}) {
+	e := encodeLive(typ, v)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[playerID] {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs[playerID], ch)
+			close(ch)
+		}
+	}
+	if len(b.subs[playerID]) == 0 {
+		delete(b.subs, playerID)
+	}
+}
+
+// publishBalance pushes p's balance. Callers hold playerMu.
+func publishBalance(p *playerState) {
+	live.publish(p.ID, liveBalance, map[string]int{"balance": p.Balance})
+}
+
+// publishSkins pushes p's owned and equipped skins. Callers hold playerMu.
+func publishSkins(p *playerState) {
+	live.publish(p.ID, liveSkins, map[string]interface{}{"ownedSkins": p.OwnedSkins, "equippedSkin": p.EquippedSkin})
+}
+
+// publishLives pushes p's extra lives. Callers hold playerMu.
+func publishLives(p *playerState) {
+	live.publish(p.ID, liveLives, map[string]int{"extraLives": p.ExtraLives})
+}
+
+// publishCart pushes the current account cart to the local player.
+func publishCart() {
+	items, total := store.GetCart()
+	live.publish(player.ID, liveCart, cartResponse(items, total))
+}
+
+// StartLiveCart pushes the cart to the local player's streams whenever the store reports a change
+// (from any path: handlers, checkout, merge, expiry), until ctx is cancelled.
+func StartLiveCart(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-store.CartChanged():
+				publishCart()
+			}
+		}
+	}()
+}
+
+// GET /api/players/{id}/events — Server-Sent Events with the player's balance, skins, extra lives and
+// (for the local player) cart. The current values are sent first, then every change as it happens.
+func GetPlayerEventsHandler(w http.ResponseWriter, r *http.Request) { // stream live updates
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	playerMu.RLock()
+	p, ok := lookupPlayer(r.PathValue("id"))
+	if !ok {
+		playerMu.RUnlock()
+		writeError(w, http.StatusNotFound, "player not found")
+		return
+	}
+	// Subscribe before reading the snapshot so no change between the two is lost.
+	events, unsubscribe := live.subscribe(p.ID)
+	defer unsubscribe()
+	snapshot := []liveEvent{
+		encodeLive(liveBalance, map[string]int{"balance": p.Balance}),
+		encodeLive(liveSkins, map[string]interface{}{"ownedSkins": p.OwnedSkins, "equippedSkin": p.EquippedSkin}),
+		encodeLive(liveLives, map[string]int{"extraLives": p.ExtraLives}),
+	}
+	isLocal := p == player
+	playerMu.RUnlock()
+	if isLocal {
+		items, total := store.GetCart()
+		snapshot = append(snapshot, encodeLive(liveCart, cartResponse(items, total)))
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range snapshot {
+		writeLive(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return // fell behind: end the stream so the client reconnects for a fresh snapshot
+			}
+			writeLive(w, e)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// encodeLive encodes an event body.
+func encodeLive(typ string, v interface{}) liveEvent {
+	data, _ := json.Marshal(v)
+	return liveEvent{typ: typ, data: data}
+}
+
+// writeLive writes one event in SSE wire format.
+func writeLive(w http.ResponseWriter, e liveEvent) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.typ, e.data)
+}
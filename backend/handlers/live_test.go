@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the next SSE event (type and data), skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) (typ, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			typ = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && typ != "":
+			return typ, data
+		}
+	}
+}
+
+func TestPlayerEvents_SnapshotThenBalance(t *testing.T) {
+	resetPlayer(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/players/{id}/events", GetPlayerEventsHandler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/players/"+player.ID+"/events", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type: %s", ct)
+	}
+	body := bufio.NewReader(res.Body)
+	for _, want := range []string{liveBalance, liveSkins, liveLives, liveCart} {
+		if typ, _ := readEvent(t, body); typ != want {
+			t.Fatalf("snapshot: want %s, got %s", want, typ)
+		}
+	}
+
+	// Coins earned in another tab show up on this stream.
+	serve(EarnCoinsHandler, http.MethodPost, "/api/earn", `{"score":100}`, nil)
+	typ, data := readEvent(t, body)
+	if typ != liveBalance || data != `{"balance":220}` {
+		t.Errorf("after earning: got %s %s", typ, data)
+	}
+}
+
+func TestLiveBroker_ClosesSlowSubscriber(t *testing.T) {
+	slow, unsubscribeSlow := live.subscribe("slow-player")
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := live.subscribe("slow-player")
+	defer unsubscribeFast()
+
+	for i := 0; i <= liveBuffer; i++ { // one more than the buffer holds
+		live.publish("slow-player", liveBalance, map[string]int{"balance": i})
+		<-fast // the fast stream keeps up
+	}
+	n := 0
+	for range slow { // ends: the stream was closed instead of missing the last event
+		n++
+	}
+	if n != liveBuffer {
+		t.Errorf("slow stream: want the %d buffered events before closing, got %d", liveBuffer, n)
+	}
+	live.publish("slow-player", liveBalance, map[string]int{"balance": -1})
+	if e := <-fast; string(e.data) != `{"balance":-1}` {
+		t.Errorf("fast stream after the slow one closed: got %s", e.data)
+	}
+}
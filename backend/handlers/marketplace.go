@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"SnakeGame/ledger"
+	"SnakeGame/models"
+)
+
+// DefaultListingTTL is how long a listing stays up when the seller does not choose a duration.
+const DefaultListingTTL = 72 * time.Hour
+
+// DefaultMarketFeePercent is the house fee taken from each sale, in percent of the price.
+const DefaultMarketFeePercent = 5
+
+// Listing statuses.
+const (
+	ListingActive    = "active"
+	ListingSold      = "sold"
+	ListingCancelled = "cancelled"
+	ListingExpired   = "expired"
+)
+
+// listing is a skin a player offered to other players for coins.
+type listing struct {
+	ID         string    `json:"id"`
+	SellerID   string    `json:"sellerId"`
+	SellerName string    `json:"sellerName"`
+	SkinID     string    `json:"skinId"`
+	SkinName   string    `json:"skinName"`
+	Price      int       `json:"price"`
+	Status     string    `json:"status"`
+	BuyerID    string    `json:"buyerId,omitempty"`
+	Fee        int       `json:"fee,omitempty"` // house fee kept when sold
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+var (
+	now           = time.Now              // clock for listing expiry (overridden in tests)
+	listings      = map[string]*listing{} // all listings by id; protected by playerMu
+	listingOrder  []string                // listing ids, oldest first; protected by playerMu
+	nextListingID = 1                     // protected by playerMu
+	marketFee     atomic.Int64            // house fee in percent
+)
+
+func init() {
+	marketFee.Store(DefaultMarketFeePercent)
+}
+
+// SetMarketFeePercent sets the house fee taken from each sale (0-100).
+func SetMarketFeePercent(percent int) {
+	if percent < 0 || percent > 100 {
+		return
+	}
+	marketFee.Store(int64(percent))
+}
+
+// actingPlayer returns the player making the request: the X-Player-ID header, or the local player
+// when it is absent. Callers hold playerMu.
+func actingPlayer(r *http.Request) (*playerState, bool) {
+	id := r.Header.Get("X-Player-ID")
+	if id == "" {
+		return player, true
+	}
+	return lookupPlayer(id)
+}
+
+// expireListings marks active listings past their expiry as expired. Callers hold playerMu for writing.
+func expireListings() {
+	t := now()
+	for _, l := range listings {
+		if l.Status == ListingActive && !t.Before(l.ExpiresAt) {
+			l.Status = ListingExpired
+		}
+	}
+}
+
+// skinListed reports whether the seller has an active listing for the skin. Callers hold playerMu.
+func skinListed(sellerID, skinID string) bool {
+	for _, l := range listings {
+		if l.Status == ListingActive && l.SellerID == sellerID && l.SkinID == skinID {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /api/market/listings — active listings, oldest first
+func GetListingsHandler(w http.ResponseWriter, r *http.Request) { // list skins for sale
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	playerMu.Lock()
+	defer playerMu.Unlock()
+	expireListings()
+	out := []listing{}
+	for _, id := range listingOrder {
+		if l := listings[id]; l.Status == ListingActive {
+			out = append(out, *l)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// POST /api/market/listings — offer an owned, non-default skin for sale
+func PostListingHandler(w http.ResponseWriter, r *http.Request) { // list a skin
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req struct { // request body for listing a skin
+		SkinID        string `json:"skinId"`
+		Price         int    `json:"price"`
+		DurationHours int    `json:"durationHours"` // optional; DefaultListingTTL when zero
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.SkinID == "" {
+		writeValidationError(w, "skinId is required")
+		return
+	}
+	if req.Price <= 0 {
+		writeValidationError(w, "price must be positive")
+		return
+	}
+	if req.DurationHours < 0 {
+		writeValidationError(w, "durationHours must not be negative")
+		return
+	}
+	allowCORS(w)
+	playerMu.Lock()
+	defer playerMu.Unlock()
+	seller, ok := actingPlayer(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "player not found")
+		return
+	}
+	if req.SkinID == "default" || !models.IsSkin(req.SkinID) {
+		writeValidationError(w, "only non-default skins can be listed")
+		return
+	}
+	if !seller.ownsSkin(req.SkinID) {
+		writeValidationError(w, "you do not own this skin")
+		return
+	}
+	expireListings()
+	if skinListed(seller.ID, req.SkinID) {
+		writeError(w, http.StatusConflict, "skin is already listed")
+		return
+	}
+	ttl := DefaultListingTTL
+	if req.DurationHours > 0 {
+		ttl = time.Duration(req.DurationHours) * time.Hour
+	}
+	name, _, _, _ := models.ItemDisplay(req.SkinID)
+	created := now()
+	l := &listing{
+		ID: "L" + strconv.Itoa(nextListingID), SellerID: seller.ID, SellerName: seller.Name, SkinID: req.SkinID, SkinName: name,
+		Price: req.Price, Status: ListingActive, CreatedAt: created, ExpiresAt: created.Add(ttl),
+	}
+	nextListingID++
+	listings[l.ID] = l
+	listingOrder = append(listingOrder, l.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(l)
+}
+
+// DELETE /api/market/listings/{id} — the seller withdraws an active listing
+func DeleteListingHandler(w http.ResponseWriter, r *http.Request) { // cancel a listing
+	if r.Method != http.MethodDelete {
+		return
+	}
+	allowCORS(w)
+	playerMu.Lock()
+	defer playerMu.Unlock()
+	expireListings()
+	l, ok := listings[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "listing not found")
+		return
+	}
+	if seller, ok := actingPlayer(r); !ok || seller.ID != l.SellerID {
+		writeError(w, http.StatusForbidden, "only the seller can cancel a listing")
+		return
+	}
+	if l.Status != ListingActive {
+		writeError(w, http.StatusConflict, "listing is "+l.Status)
+		return
+	}
+	l.Status = ListingCancelled
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l)
+}
+
+// POST /api/market/listings/{id}/buy — buy a listed skin. The price is escrowed from the buyer,
+// the skin moves from seller to buyer, and the escrow pays the seller minus the house fee, all
+// under one lock so no partial trade is ever visible.
+func BuyListingHandler(w http.ResponseWriter, r *http.Request) { // buy a listed skin
+	if r.Method != http.MethodPost {
+		return
+	}
+	allowCORS(w)
+	playerMu.Lock()
+	defer playerMu.Unlock()
+	expireListings()
+	l, ok := listings[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "listing not found")
+		return
+	}
+	buyer, ok := actingPlayer(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "player not found")
+		return
+	}
+	if l.Status != ListingActive {
+		writeError(w, http.StatusConflict, "listing is "+l.Status)
+		return
+	}
+	seller, ok := lookupPlayer(l.SellerID)
+	if !ok || !seller.ownsSkin(l.SkinID) {
+		l.Status = ListingCancelled // the seller no longer has the skin to hand over
+		writeError(w, http.StatusConflict, "seller no longer owns this skin")
+		return
+	}
+	if buyer == seller {
+		writeValidationError(w, "cannot buy your own listing")
+		return
+	}
+	if buyer.ownsSkin(l.SkinID) {
+		writeError(w, http.StatusConflict, "you already own this skin")
+		return
+	}
+	if buyer.Balance < l.Price {
+		writeError(w, http.StatusConflict, "not enough coins")
+		return
+	}
+
+	fee := l.Price * int(marketFee.Load()) / 100
+	buyer.Balance -= l.Price
+	ledger.Record(ledger.TypeEscrowHold, buyer.ID, ledger.AccountEscrow, l.Price, l.ID)
+
+	for i, s := range seller.OwnedSkins {
+		if s == l.SkinID {
+			seller.OwnedSkins = append(seller.OwnedSkins[:i], seller.OwnedSkins[i+1:]...)
+			break
+		}
+	}
+	if seller.EquippedSkin == l.SkinID {
+		seller.EquippedSkin = "default"
+	}
+	buyer.OwnedSkins = append(buyer.OwnedSkins, l.SkinID)
+
+	seller.Balance += l.Price - fee
+	ledger.Record(ledger.TypeEscrowRelease, ledger.AccountEscrow, seller.ID, l.Price-fee, l.ID)
+	if fee > 0 {
+		ledger.Record(ledger.TypeFee, ledger.AccountEscrow, ledger.AccountHouse, fee, l.ID)
+	}
+	l.Status, l.BuyerID, l.Fee = ListingSold, buyer.ID, fee
+	publishBalance(buyer)
+	publishSkins(buyer)
+	publishBalance(seller)
+	publishSkins(seller)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"listing":    l,
+		"balance":    buyer.Balance,
+		"ownedSkins": buyer.OwnedSkins,
+	})
+}
+
+// GET /api/admin/ledger — every recorded coin movement, oldest first
+func GetLedgerHandler(w http.ResponseWriter, r *http.Request) { // list ledger entries
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": ledger.Entries(),
+		"house":   ledger.Balance(ledger.AccountHouse),
+	})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"SnakeGame/ledger"
+)
+
+// registerFriend adds another player with the given balance and returns its id.
+func registerFriend(t *testing.T, balance int) string {
+	t.Helper()
+	rec := serve(PostPlayersHandler, http.MethodPost, "/api/players", `{"name":"Buyer"}`, nil)
+	var p playerState
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("register player: %v", err)
+	}
+	playerMu.Lock()
+	players[p.ID].Balance = balance
+	playerMu.Unlock()
+	return p.ID
+}
+
+// withPath runs h with a path value set, as the mux would.
+func withPath(h http.HandlerFunc, id string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.SetPathValue("id", id)
+		h(w, r)
+	}
+}
+
+func TestMarketplace_BuyMovesSkinAndPaysFee(t *testing.T) {
+	resetPlayer(t)
+	playerMu.Lock()
+	player.OwnedSkins = append(player.OwnedSkins, "skin_gold")
+	player.EquippedSkin = "skin_gold"
+	playerMu.Unlock()
+	buyerID := registerFriend(t, 150)
+	houseBefore := ledger.Balance(ledger.AccountHouse)
+
+	rec := serve(PostListingHandler, http.MethodPost, "/api/market/listings", `{"skinId":"skin_gold","price":100}`, nil)
+	var l listing
+	if err := json.Unmarshal(rec.Body.Bytes(), &l); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("list skin: %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(withPath(BuyListingHandler, l.ID), http.MethodPost, "/api/market/listings/"+l.ID+"/buy", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("buying your own listing must fail, got %d", rec.Code)
+	}
+	rec = serve(withPath(BuyListingHandler, l.ID), http.MethodPost, "/api/market/listings/"+l.ID+"/buy", "", map[string]string{"X-Player-ID": buyerID})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("buy: %d %s", rec.Code, rec.Body)
+	}
+
+	playerMu.RLock()
+	defer playerMu.RUnlock()
+	buyer := players[buyerID]
+	if player.ownsSkin("skin_gold") || !buyer.ownsSkin("skin_gold") {
+		t.Errorf("skin not moved: seller %v, buyer %v", player.OwnedSkins, buyer.OwnedSkins)
+	}
+	if player.EquippedSkin != "default" {
+		t.Errorf("seller equipped skin: want default, got %s", player.EquippedSkin)
+	}
+	if player.Balance != 295 || buyer.Balance != 50 {
+		t.Errorf("balances: seller %d (want 295), buyer %d (want 50)", player.Balance, buyer.Balance)
+	}
+	if got := ledger.Balance(ledger.AccountHouse) - houseBefore; got != 5 {
+		t.Errorf("house fee: want 5, got %d", got)
+	}
+	if ledger.Balance(ledger.AccountEscrow) != 0 {
+		t.Errorf("escrow must be settled, holds %d", ledger.Balance(ledger.AccountEscrow))
+	}
+}
+
+func TestMarketplace_CancelAndExpire(t *testing.T) {
+	resetPlayer(t)
+	playerMu.Lock()
+	player.OwnedSkins = append(player.OwnedSkins, "skin_ice")
+	playerMu.Unlock()
+	buyerID := registerFriend(t, 500)
+	t.Cleanup(func() { now = time.Now })
+
+	list := func() listing {
+		rec := serve(PostListingHandler, http.MethodPost, "/api/market/listings", `{"skinId":"skin_ice","price":40,"durationHours":1}`, nil)
+		var l listing
+		json.Unmarshal(rec.Body.Bytes(), &l)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("list skin: %d %s", rec.Code, rec.Body)
+		}
+		return l
+	}
+
+	l := list()
+	rec := serve(withPath(DeleteListingHandler, l.ID), http.MethodDelete, "/api/market/listings/"+l.ID, "", map[string]string{"X-Player-ID": buyerID})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("cancel by another player: want 403, got %d", rec.Code)
+	}
+	rec = serve(withPath(DeleteListingHandler, l.ID), http.MethodDelete, "/api/market/listings/"+l.ID, "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", rec.Code, rec.Body)
+	}
+
+	l = list()
+	now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	rec = serve(withPath(BuyListingHandler, l.ID), http.MethodPost, "/api/market/listings/"+l.ID+"/buy", "", map[string]string{"X-Player-ID": buyerID})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("buy expired listing: want 409, got %d %s", rec.Code, rec.Body)
+	}
+	if !player.ownsSkin("skin_ice") {
+		t.Error("an expired listing must leave the skin with the seller")
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"SnakeGame/payment"
+)
+
+// paymentFailure is how a gateway error is retried and reported to the player.
+type paymentFailure struct {
+	code      string // machine-readable "Code" in the response
+	status    int    // HTTP status of the response
+	retryable bool   // whether paymentRetry tries the gateway again
+	message   string // "Message" in the response
+}
+
+// paymentFailures maps gateway errors to failures, most specific first (insufficient funds and
+// suspected fraud are also declines).
+var paymentFailures = []struct {
+	err     error
+	failure paymentFailure
+}{
+	{payment.ErrInsufficientFunds, paymentFailure{"insufficient_funds", http.StatusUnprocessableEntity, false, "Your card has insufficient funds."}},
+	{payment.ErrFraudSuspected, paymentFailure{"fraud_suspected", http.StatusForbidden, false, "This payment was blocked by the card issuer. Please contact your bank."}},
+	{payment.ErrDeclined, paymentFailure{"card_declined", http.StatusPaymentRequired, false, "Your card was declined. Please use a different payment method."}},
+	{payment.ErrRateLimited, paymentFailure{"rate_limited", http.StatusTooManyRequests, true, "Too many payment attempts. Please try again shortly."}},
+	{payment.ErrInvalidRequest, paymentFailure{"invalid_payment_request", http.StatusBadRequest, false, "The payment request was invalid."}},
+	{payment.ErrIdempotencyConflict, paymentFailure{"idempotency_conflict", http.StatusConflict, false, "This Idempotency-Key was already used for a different payment."}},
+	{payment.ErrAuthorizationState, paymentFailure{"authorization_state", http.StatusConflict, false, "This payment was already settled."}},
+	{payment.ErrUnauthorized, paymentFailure{"payment_misconfigured", http.StatusBadGateway, false, "Payments are not available right now."}},
+	{payment.ErrTimeout, paymentFailure{"payment_timeout", http.StatusGatewayTimeout, true, "The payment provider did not respond. Please try again."}},
+}
+
+// providerOutage is the failure for a provider outage and for any error not listed above.
+var providerOutage = paymentFailure{"provider_unavailable", http.StatusServiceUnavailable, true, "Payment temporarily unavailable. Please try again."}
+
+// classifyPayment returns the failure for a gateway error.
+func classifyPayment(err error) paymentFailure {
+	for _, f := range paymentFailures {
+		if errors.Is(err, f.err) {
+			return f.failure
+		}
+	}
+	return providerOutage
+}
+
+// paymentRetryAfter returns the provider's Retry-After hint in whole seconds (rounded up), or 0.
+func paymentRetryAfter(err error) int {
+	var perr *payment.ProviderError
+	if !errors.As(err, &perr) || perr.RetryAfter <= 0 {
+		return 0
+	}
+	return int((perr.RetryAfter + time.Second - 1) / time.Second)
+}
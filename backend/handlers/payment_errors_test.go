@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"SnakeGame/models"
+	"SnakeGame/payment"
+)
+
+// countingGateway fails every charge and authorization with err and counts the attempts.
+type countingGateway struct {
+	err   error
+	calls int
+}
+
+func (g *countingGateway) Charge(ctx context.Context, amountCents int, idempotencyKey string) error {
+	g.calls++
+	return g.err
+}
+
+func (g *countingGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	g.calls++
+	return "", g.err
+}
+
+func (g *countingGateway) Capture(ctx context.Context, transactionID string) error { return g.err }
+
+func (g *countingGateway) Void(ctx context.Context, transactionID string) error { return g.err }
+
+func TestCoinPack_PaymentErrorTaxonomy(t *testing.T) {
+	pack, _ := models.CoinPackByID("coins_500")
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{&payment.ProviderError{StatusCode: 402, Code: "card_declined", Err: payment.ErrDeclined}, http.StatusPaymentRequired, "card_declined"},
+		{&payment.ProviderError{StatusCode: 402, Code: "insufficient_funds", Err: payment.ErrInsufficientFunds}, http.StatusUnprocessableEntity, "insufficient_funds"},
+		{&payment.ProviderError{StatusCode: 402, Code: "fraudulent", Err: payment.ErrFraudSuspected}, http.StatusForbidden, "fraud_suspected"},
+		{&payment.ProviderError{StatusCode: 400, Err: payment.ErrInvalidRequest}, http.StatusBadRequest, "invalid_payment_request"},
+	}
+	for _, tc := range cases {
+		resetPlayer(t)
+		gw := &countingGateway{err: tc.err}
+		status, body, _ := buyCoinPack(context.Background(), gw, pack, "")
+		var out struct{ Status, Code, Message string }
+		json.Unmarshal(body, &out)
+		if status != tc.status || out.Code != tc.code || out.Status != "Fail" {
+			t.Errorf("%v: want %d %s, got %d %s", tc.err, tc.status, tc.code, status, body)
+		}
+		if gw.calls != 1 {
+			t.Errorf("%v: a non-retryable error must not be retried; %d attempts", tc.err, gw.calls)
+		}
+		if player.Balance != 200 {
+			t.Errorf("%v: no coins may be credited; balance %d", tc.err, player.Balance)
+		}
+	}
+}
+
+func TestCoinPack_RateLimitPassesRetryAfterOn(t *testing.T) {
+	resetPlayer(t)
+	fake := payment.NewFakeProvider()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	SetPaymentGateway(&payment.HTTPGateway{BaseURL: srv.URL})
+	t.Cleanup(func() { SetPaymentGateway(&payment.StubGateway{}) })
+
+	// The provider asks for a minute, longer than the retry loop waits: answer 429 right away.
+	fake.FailNext(payment.FakeFailure{Status: http.StatusTooManyRequests, Code: "rate_limited", RetryAfter: 60})
+	buy := withPath(PostCoinPackPurchaseHandler, "coins_500")
+	rec := serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("want 429 with Retry-After 60, got %d %q %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body)
+	}
+	var out struct {
+		Code       string
+		RetryAfter int
+	}
+	json.NewDecoder(rec.Body).Decode(&out)
+	if out.Code != "rate_limited" || out.RetryAfter != 60 {
+		t.Errorf("body: %+v", out)
+	}
+
+	// A short hint is waited out and the charge retried.
+	fake.FailNext(payment.FakeFailure{Status: http.StatusTooManyRequests, Code: "rate_limited", RetryAfter: 1})
+	if rec := serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("short rate limit: want success after waiting, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"SnakeGame/models"
+)
+
+// maxGiftMessage is the longest gift message accepted, in characters.
+const maxGiftMessage = 200
+
+// giftItem is one line delivered by a gift.
+type giftItem struct {
+	ItemID   string `json:"ItemID"`
+	Name     string `json:"Name"`
+	Quantity int    `json:"Quantity"`
+}
+
+// gift is the record a recipient sees for items another player bought for them.
+type gift struct {
+	ID        int        `json:"ID"`
+	FromID    string     `json:"FromID"`
+	FromName  string     `json:"FromName"`
+	Items     []giftItem `json:"Items"`
+	Message   string     `json:"Message,omitempty"`
+	CreatedAt time.Time  `json:"CreatedAt"`
+}
+
+var (
+	players    = map[string]*playerState{player.ID: player} // registered players by id; protected by playerMu
+	nextGiftID = 1                                          // id of the next gift record; protected by playerMu
+)
+
+// lookupPlayer returns a registered player. Callers hold playerMu.
+func lookupPlayer(id string) (*playerState, bool) {
+	p, ok := players[id]
+	return p, ok
+}
+
+// recordGift stores a gift on the recipient's inventory. Callers hold playerMu for writing.
+func recordGift(from, to *playerState, items []models.CartItem, message string) gift {
+	g := gift{ID: nextGiftID, FromID: from.ID, FromName: from.Name, Items: []giftItem{}, Message: message, CreatedAt: time.Now()}
+	nextGiftID++
+	for _, it := range items {
+		g.Items = append(g.Items, giftItem{ItemID: it.ItemID, Name: it.Name, Quantity: it.Quantity})
+	}
+	to.Gifts = append(to.Gifts, g)
+	return g
+}
+
+// giftConflicts returns the names of cart skins the recipient already owns. Callers hold playerMu.
+func giftConflicts(recipient *playerState, items []models.CartItem) []string {
+	owned := []string{}
+	for _, it := range items {
+		if models.IsSkin(it.ItemID) && recipient.ownsSkin(it.ItemID) {
+			owned = append(owned, it.Name)
+		}
+	}
+	return owned
+}
+
+// POST /api/players — register another player (a friend who can receive gifts)
+func PostPlayersHandler(w http.ResponseWriter, r *http.Request) { // register a player
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req struct { // request body for registering a player
+		Name string `json:"name"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || strings.TrimSpace(req.Name) == "" {
+		writeValidationError(w, "name is required")
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+	playerMu.Lock()
+	defer playerMu.Unlock()
+	p := &playerState{
+		ID:           fmt.Sprintf("player%d", len(players)+1),
+		Name:         strings.TrimSpace(req.Name),
+		Balance:      200,
+		OwnedSkins:   []string{"default"},
+		EquippedSkin: "default",
+		Gifts:        []gift{},
+	}
+	players[p.ID] = p
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+// GET /api/players/{id}/gifts — gifts a player received, with the sender's name
+func GetPlayerGiftsHandler(w http.ResponseWriter, r *http.Request) { // list received gifts
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	playerMu.RLock()
+	defer playerMu.RUnlock()
+	p, ok := lookupPlayer(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "player not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"playerId": p.ID, "gifts": p.Gifts})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SnakeGame/store"
+)
+
+// POST /api/user/purchases — buy exactly one unit of one catalog item right away (e.g. the extra life on
+// the game-over screen). Pricing, stock, the coin debit and the Idempotency-Key header work as in checkout;
+// the cart, its coupon and its version are left untouched.
+func PostPurchaseHandler(w http.ResponseWriter, r *http.Request) { // instant single-item purchase
+	if r.Method != http.MethodPost {
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+
+	// Claim the key first so a concurrent retry waits for this request and replays its response.
+	key := r.Header.Get("Idempotency-Key") // idempotency key
+	if status, cached, ok := claimIdempotency(key); ok {
+		w.WriteHeader(status)
+		w.Write(cached)
+		return
+	}
+	defer releaseIdempotency(key) // validation errors are not cached and leave the key free
+
+	var req struct { // request body for an instant purchase
+		ItemID string `json:"itemId"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.ItemID == "" {
+		writeValidationError(w, "invalid itemId")
+		return
+	}
+	line, err := store.PurchaseLine(req.ItemID, ownedSkins())
+	if err != nil {
+		writeValidationError(w, err.Error())
+		return
+	}
+
+	status, body := runCheckout(checkoutOptions{idempotencyKey: key, purchase: &line})
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"SnakeGame/store"
+)
+
+func TestPurchase_LeavesCartUntouched(t *testing.T) {
+	resetPlayer(t)
+	store.AddToCart("skin_gold", nil)
+	_, _, version := store.GetCartWithVersion()
+
+	headers := map[string]string{"Idempotency-Key": "instant-life-1"}
+	for i := 0; i < 2; i++ { // the retry replays the cached response instead of charging again
+		rec := serve(PostPurchaseHandler, http.MethodPost, "/api/user/purchases", `{"itemId":"extra_life"}`, headers)
+		var out map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &out)
+		if rec.Code != http.StatusOK || out["Status"] != "Success" {
+			t.Fatalf("purchase %d: %d %s", i, rec.Code, rec.Body)
+		}
+	}
+	if player.Balance != 150 || player.ExtraLives != 1 {
+		t.Errorf("balance %d lives %d, want 150 and 1", player.Balance, player.ExtraLives)
+	}
+	if player.ownsSkin("skin_gold") {
+		t.Error("the skin in the cart must not be bought")
+	}
+	if items, _, v := store.GetCartWithVersion(); len(items) != 1 || v != version {
+		t.Errorf("cart changed: %d lines, version %d -> %d", len(items), version, v)
+	}
+
+	rec := serve(PostPurchaseHandler, http.MethodPost, "/api/user/purchases", `{"itemId":"default"}`, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("default skin: want 400, got %d", rec.Code)
+	}
+}
+
+func TestPurchase_ConcurrentSameKeyChargesOnce(t *testing.T) {
+	resetPlayer(t)
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, 8)
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = serve(PostPurchaseHandler, http.MethodPost, "/api/user/purchases", `{"itemId":"extra_life"}`,
+				map[string]string{"Idempotency-Key": "instant-concurrent-1"})
+		}()
+	}
+	wg.Wait()
+	for _, rec := range recs {
+		if rec.Code != http.StatusOK || rec.Body.String() != recs[0].Body.String() {
+			t.Errorf("every request must get the one purchase's response; got %d %s", rec.Code, rec.Body)
+		}
+	}
+	if player.Balance != 150 || player.ExtraLives != 1 {
+		t.Errorf("balance %d lives %d, want 150 and 1 (one life bought)", player.Balance, player.ExtraLives)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"SnakeGame/models"
+	"SnakeGame/store"
+)
+
+// quoteLine is a cart line that will be charged.
+type quoteLine struct {
+	ID        string `json:"id"`
+	ItemID    string `json:"itemId"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int    `json:"unitPrice"`
+	Charge    int    `json:"charge"`
+}
+
+// skippedLine is a cart line checkout will not charge for, with the reason.
+type skippedLine struct {
+	ID     string `json:"id"`
+	ItemID string `json:"itemId"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// checkoutQuote is the pricing of a cart exactly as doCheckout computes it.
+type checkoutQuote struct {
+	Lines          []quoteLine   `json:"lines"`
+	Skipped        []skippedLine `json:"skipped"`
+	Subtotal       int           `json:"subtotal"`     // sum of charged lines
+	SaleDiscount   int           `json:"saleDiscount"` // list price minus sale price on charged lines
+	CouponCode     string        `json:"couponCode,omitempty"`
+	CouponDiscount int           `json:"couponDiscount"` // coupon discount on charged lines
+	Total          int           `json:"total"`          // coins checkout will deduct
+	Balance        int           `json:"balance"`
+	BalanceAfter   int           `json:"balanceAfter"` // projected balance after purchase
+
+	charged []models.CartItem // lines to pay for and deliver (skins clamped to quantity 1)
+}
+
+// priceCheckout prices items paid by buyer and delivered to owner (the buyer, or a gift recipient):
+// skins owner already has are skipped, skins count once, and the coupon (if any) discounts the
+// charged lines. Callers hold playerMu.
+func priceCheckout(buyer, owner *playerState, items []models.CartItem, coupon *store.Coupon) checkoutQuote {
+	q := checkoutQuote{Lines: []quoteLine{}, Skipped: []skippedLine{}, Balance: buyer.Balance}
+	for _, it := range items {
+		if models.IsSkin(it.ItemID) {
+			if owner.ownsSkin(it.ItemID) {
+				q.Skipped = append(q.Skipped, skippedLine{ID: it.ID, ItemID: it.ItemID, Name: it.Name, Reason: "already owned"})
+				continue
+			}
+			it.Quantity = 1 // a skin is owned once, however many times it was added
+		}
+		q.charged = append(q.charged, it)
+		q.Lines = append(q.Lines, quoteLine{
+			ID: it.ID, ItemID: it.ItemID, Name: it.Name, Quantity: it.Quantity, UnitPrice: it.Price, Charge: it.Price * it.Quantity,
+		})
+		q.Subtotal += it.Price * it.Quantity
+		q.SaleDiscount += (it.OriginalPrice - it.Price) * it.Quantity
+	}
+	q.Total = q.Subtotal
+	if coupon != nil {
+		q.CouponCode = coupon.Code
+		q.CouponDiscount = coupon.Discount(q.charged)
+		q.Total -= q.CouponDiscount
+	}
+	q.BalanceAfter = q.Balance - q.Total
+	return q
+}
+
+// GET /api/user/cart/quote — price the cart exactly as checkout would, without committing anything.
+// ?recipientId= quotes the cart as a gift to that player.
+func GetCartQuoteHandler(w http.ResponseWriter, r *http.Request) { // quote the checkout
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	playerMu.RLock()
+	owner := player
+	if id := r.URL.Query().Get("recipientId"); id != "" {
+		recipient, ok := lookupPlayer(id)
+		if !ok || recipient == player {
+			playerMu.RUnlock()
+			writeValidationError(w, "unknown gift recipient")
+			return
+		}
+		owner = recipient
+	}
+	playerMu.RUnlock()
+	items, version, changed, removed := store.PreviewReprice()
+	var problems []string // reasons checkout would currently fail
+	if len(changed) > 0 || len(removed) > 0 {
+		problems = append(problems, "prices changed since items were added; checkout will ask to confirm")
+	}
+	if len(items) == 0 {
+		problems = append(problems, "cart is empty")
+	}
+	var coupon *store.Coupon
+	if c, ok := store.CartCoupon(); ok {
+		if err := store.CheckCoupon(c.Code, player.ID); err != nil {
+			problems = append(problems, "coupon "+c.Code+" cannot be used: "+err.Error())
+		} else {
+			coupon = &c
+		}
+	}
+
+	playerMu.RLock()
+	if owner != player {
+		if owned := giftConflicts(owner, items); len(owned) > 0 {
+			problems = append(problems, owner.Name+" already owns "+strings.Join(owned, ", "))
+		}
+	}
+	q := priceCheckout(player, owner, items, coupon)
+	playerMu.RUnlock()
+
+	if q.BalanceAfter < 0 {
+		problems = append(problems, "not enough coins")
+	}
+	for _, it := range q.charged {
+		if level, limited := models.Stock(it.ItemID); limited && level.Remaining < it.Quantity {
+			problems = append(problems, it.Name+" is sold out")
+		}
+	}
+	if problems == nil {
+		problems = []string{}
+	}
+	if changed == nil {
+		changed = []store.PriceChange{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", cartETag(version))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"quote":        q,
+		"priceChanges": changed,
+		"canCheckout":  len(problems) == 0,
+		"problems":     problems,
+	})
+}
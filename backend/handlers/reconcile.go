@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"SnakeGame/payment"
+	"SnakeGame/reconcile"
+)
+
+var (
+	reconcileMu     sync.Mutex        // serializes runs; protects lastReconcile and reconcileReport
+	lastReconcile   *reconcile.Report // most recent run, nil before the first
+	reconcileReport string            // file each report is written to ("" writes none)
+)
+
+// SetReconcileReportPath sets the file every reconciliation report is written to.
+func SetReconcileReportPath(path string) {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	reconcileReport = path
+}
+
+// runReconciliation reconciles orders against the configured gateway's charges, keeps the report
+// for the admin API and writes it to the report file.
+func runReconciliation(ctx context.Context) (reconcile.Report, error) {
+	gatewayMu.RLock()
+	lister, ok := gateway.(payment.ChargeLister)
+	gatewayMu.RUnlock()
+	if !ok {
+		return reconcile.Report{}, payment.ErrListNotSupported
+	}
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	report, err := reconcile.Run(ctx, lister)
+	if err != nil {
+		return report, err
+	}
+	lastReconcile = &report
+	if reconcileReport != "" {
+		if err := reconcile.WriteFile(reconcileReport, report); err != nil {
+			log.Printf("reconciliation report: %v", err)
+		}
+	}
+	return report, nil
+}
+
+// StartReconciliation runs the reconciliation every interval until ctx is cancelled, logging runs
+// that find discrepancies.
+func StartReconciliation(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := runReconciliation(ctx)
+				if err != nil {
+					log.Printf("reconciliation failed: %v", err)
+				} else if !report.OK {
+					log.Printf("reconciliation found %d discrepancies", len(report.Findings))
+				}
+			}
+		}
+	}()
+}
+
+// POST /api/admin/reconciliation — reconcile orders and ledger credits against the gateway's charges now
+func PostReconciliationHandler(w http.ResponseWriter, r *http.Request) { // run reconciliation
+	if r.Method != http.MethodPost {
+		return
+	}
+	allowCORS(w)
+	report, err := runReconciliation(r.Context())
+	if errors.Is(err, payment.ErrListNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GET /api/admin/reconciliation — the most recent reconciliation report
+func GetReconciliationHandler(w http.ResponseWriter, r *http.Request) { // get the last reconciliation
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	reconcileMu.Lock()
+	report := lastReconcile
+	reconcileMu.Unlock()
+	if report == nil {
+		writeError(w, http.StatusNotFound, "no reconciliation has run yet")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"SnakeGame/orders"
+	"SnakeGame/payment"
+	"SnakeGame/reconcile"
+)
+
+func TestReconciliation_AdminAPIAndReportFile(t *testing.T) {
+	resetPlayer(t)
+	stub := &payment.StubGateway{}
+	SetPaymentGateway(stub)
+	path := filepath.Join(t.TempDir(), "reconciliation.json")
+	SetReconcileReportPath(path)
+	t.Cleanup(func() {
+		SetPaymentGateway(&payment.StubGateway{})
+		SetReconcileReportPath("")
+	})
+
+	buy := withPath(PostCoinPackPurchaseHandler, "coins_500")
+	if rec := serve(buy, http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "", map[string]string{"Idempotency-Key": "pack-recon-1"}); rec.Code != http.StatusOK {
+		t.Fatalf("purchase: %d %s", rec.Code, rec.Body)
+	}
+	order, _ := orders.ByPaymentKey("pack-recon-1")
+	stub.Charge(context.Background(), 1999, "pack-recon-orphan") // charged by the provider, never ordered
+
+	rec := serve(PostReconciliationHandler, http.MethodPost, "/api/admin/reconciliation", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reconcile: %d %s", rec.Code, rec.Body)
+	}
+	var report reconcile.Report
+	json.NewDecoder(rec.Body).Decode(&report)
+	var orphan bool
+	for _, f := range report.Findings {
+		if f.OrderID == order.ID {
+			t.Errorf("a charged, fulfilled order must reconcile cleanly: %+v", f)
+		}
+		if f.Kind == reconcile.KindOrphanCharge && f.IdempotencyKey == "pack-recon-orphan" && f.ChargedCents == 1999 {
+			orphan = true
+		}
+	}
+	if !orphan {
+		t.Errorf("want the orphan charge reported, got %+v", report.Findings)
+	}
+
+	if rec := serve(GetReconciliationHandler, http.MethodGet, "/api/admin/reconciliation", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("last report: %d", rec.Code)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("report file: %v", err)
+	}
+
+	SetPaymentGateway(&recordingGateway{})
+	if rec := serve(PostReconciliationHandler, http.MethodPost, "/api/admin/reconciliation", "", nil); rec.Code != http.StatusNotImplemented {
+		t.Errorf("gateway without charge listing: want 501, got %d", rec.Code)
+	}
+}
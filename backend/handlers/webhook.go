@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"SnakeGame/orders"
+	"SnakeGame/payment"
+)
+
+// maxWebhookBody bounds the webhook request body.
+const maxWebhookBody = 1 << 20
+
+var (
+	webhookMu       sync.Mutex          // protects webhookSecret and processedEvents; not held while an order settles
+	webhookSecret   string              // shared secret for payment.VerifyWebhook ("" rejects every event)
+	processedEvents = map[string]bool{} // provider event ids already handled
+)
+
+// SetWebhookSecret sets the secret the payment provider signs webhook bodies with.
+func SetWebhookSecret(secret string) {
+	webhookMu.Lock()
+	defer webhookMu.Unlock()
+	webhookSecret = secret
+}
+
+// POST /api/payments/webhook — payment provider events. The body must be signed with the shared
+// secret (payment.SignatureHeader). Each event id is handled once: redeliveries return 200 without
+// effect. charge.succeeded fulfills the pending order and captures its authorization (settleOrder),
+// charge.failed fails it; transitions the order
+// state machine rejects (e.g. failing a fulfilled order) return 409. An event is marked processed
+// before its order is touched, so the capture runs without webhookMu and a concurrent redelivery is
+// answered as a duplicate.
+func PaymentWebhookHandler(w http.ResponseWriter, r *http.Request) { // receive payment provider events
+	if r.Method != http.MethodPost {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeValidationError(w, "could not read body")
+		return
+	}
+	webhookMu.Lock()
+	secret := webhookSecret
+	webhookMu.Unlock()
+	if err := payment.VerifyWebhook(secret, body, r.Header.Get(payment.SignatureHeader)); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	var event payment.WebhookEvent
+	if json.Unmarshal(body, &event) != nil || event.ID == "" {
+		writeValidationError(w, "invalid event")
+		return
+	}
+	webhookMu.Lock()
+	duplicate := processedEvents[event.ID]
+	processedEvents[event.ID] = true
+	webhookMu.Unlock()
+	if duplicate {
+		writeWebhookResult(w, event.ID, "duplicate")
+		return
+	}
+
+	switch event.Type {
+	case payment.EventChargeSucceeded, payment.EventChargeFailed:
+		order, err := orders.ByPaymentKey(event.Data.IdempotencyKey)
+		if err != nil {
+			webhookMu.Lock()
+			delete(processedEvents, event.ID) // a redelivery may find the order
+			webhookMu.Unlock()
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if event.Data.AmountCents != 0 && event.Data.AmountCents != order.AmountCents {
+			writeError(w, http.StatusConflict, "amount does not match the order")
+			return
+		}
+		if event.Type == payment.EventChargeSucceeded {
+			gatewayMu.RLock()
+			gw := gateway
+			gatewayMu.RUnlock()
+			_, _, err = settleOrder(r.Context(), gw, order.ID)
+		} else {
+			_, err = orders.Transition(order.ID, orders.StatusFailed, event.Data.FailureReason)
+		}
+		if errors.Is(err, orders.ErrInvalidTransition) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeWebhookResult(w, event.ID, "processed")
+	default:
+		writeWebhookResult(w, event.ID, "ignored")
+	}
+}
+
+// writeWebhookResult acknowledges an event.
+func writeWebhookResult(w http.ResponseWriter, eventID, result string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"eventId": eventID, "result": result})
+}
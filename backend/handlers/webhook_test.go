@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"SnakeGame/orders"
+	"SnakeGame/payment"
+)
+
+// sendWebhook posts a provider event signed with secret.
+func sendWebhook(secret, eventID, eventType, paymentKey string) int {
+	event := map[string]interface{}{"id": eventID, "type": eventType, "data": map[string]interface{}{"idempotencyKey": paymentKey}}
+	body, _ := json.Marshal(event)
+	rec := serve(PaymentWebhookHandler, http.MethodPost, "/api/payments/webhook", string(body),
+		map[string]string{payment.SignatureHeader: payment.SignWebhook(secret, body)})
+	return rec.Code
+}
+
+func TestWebhook_SettlesPendingOrder(t *testing.T) {
+	resetPlayer(t)
+	SetWebhookSecret("whsec_test")
+	stub := &payment.StubGateway{SimulatePending: true}
+	SetPaymentGateway(stub)
+	t.Cleanup(func() {
+		SetPaymentGateway(&payment.StubGateway{})
+		SetWebhookSecret("")
+	})
+
+	rec := serve(withPath(PostCoinPackPurchaseHandler, "coins_500"), http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "",
+		map[string]string{"Idempotency-Key": "pending-pack-1"})
+	var out map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusAccepted || out["Status"] != "Pending" {
+		t.Fatalf("async provider: want 202 Pending, got %d %s", rec.Code, rec.Body)
+	}
+	if player.Balance != 200 {
+		t.Fatalf("coins must be withheld while pending; balance %d", player.Balance)
+	}
+	orderID, _ := out["OrderID"].(string)
+
+	if code := sendWebhook("wrong-secret", "evt_1", payment.EventChargeSucceeded, "pending-pack-1"); code != http.StatusUnauthorized {
+		t.Errorf("bad signature: want 401, got %d", code)
+	}
+	for i := 0; i < 2; i++ { // the redelivery is deduplicated by event id
+		if code := sendWebhook("whsec_test", "evt_1", payment.EventChargeSucceeded, "pending-pack-1"); code != http.StatusOK {
+			t.Fatalf("delivery %d: want 200, got %d", i, code)
+		}
+	}
+	if player.Balance != 700 {
+		t.Errorf("balance after confirmation: want 700, got %d", player.Balance)
+	}
+	if o, _ := orders.Get(orderID); o.Status != orders.StatusSucceeded {
+		t.Errorf("order status: want succeeded, got %s", o.Status)
+	}
+	if auths := stub.Authorizations(); len(auths) != 1 || auths[0].State != payment.AuthCaptured {
+		t.Errorf("the confirmed authorization must be captured, got %+v", auths)
+	}
+
+	if code := sendWebhook("whsec_test", "evt_2", payment.EventChargeFailed, "pending-pack-1"); code != http.StatusConflict {
+		t.Errorf("failing a fulfilled order: want 409, got %d", code)
+	}
+	if player.Balance != 700 {
+		t.Errorf("balance changed after rejected transition: %d", player.Balance)
+	}
+}
+
+// slowCaptureGateway holds every capture until release is closed.
+type slowCaptureGateway struct {
+	*payment.StubGateway
+	capturing chan struct{} // receives when a capture starts
+	release   chan struct{}
+}
+
+func (g *slowCaptureGateway) Capture(ctx context.Context, transactionID string) error {
+	g.capturing <- struct{}{}
+	<-g.release
+	return g.StubGateway.Capture(ctx, transactionID)
+}
+
+func TestWebhook_CaptureDoesNotBlockOtherEvents(t *testing.T) {
+	resetPlayer(t)
+	SetWebhookSecret("whsec_test")
+	stub := &payment.StubGateway{SimulatePending: true}
+	SetPaymentGateway(stub)
+	t.Cleanup(func() {
+		SetPaymentGateway(&payment.StubGateway{})
+		SetWebhookSecret("")
+	})
+	rec := serve(withPath(PostCoinPackPurchaseHandler, "coins_500"), http.MethodPost, "/api/user/coin-packs/coins_500/purchase", "",
+		map[string]string{"Idempotency-Key": "slow-capture-1"})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("async provider: want 202, got %d %s", rec.Code, rec.Body)
+	}
+	gw := &slowCaptureGateway{StubGateway: stub, capturing: make(chan struct{}, 1), release: make(chan struct{})}
+	SetPaymentGateway(gw)
+
+	settled := make(chan int)
+	go func() {
+		settled <- sendWebhook("whsec_test", "evt_slow_1", payment.EventChargeSucceeded, "slow-capture-1")
+	}()
+	<-gw.capturing
+
+	// While the capture hangs, a redelivery and an unrelated event are answered right away.
+	answered := make(chan [2]int)
+	go func() {
+		answered <- [2]int{
+			sendWebhook("whsec_test", "evt_slow_1", payment.EventChargeSucceeded, "slow-capture-1"),
+			sendWebhook("whsec_test", "evt_slow_2", "charge.refunded", "slow-capture-1"),
+		}
+	}()
+	select {
+	case codes := <-answered:
+		if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+			t.Errorf("redelivery and other event: want 200 and 200, got %v", codes)
+		}
+	case <-time.After(2 * time.Second):
+		close(gw.release)
+		t.Fatal("webhooks blocked behind a capture in progress")
+	}
+	close(gw.release)
+	if code := <-settled; code != http.StatusOK {
+		t.Errorf("settling delivery: want 200, got %d", code)
+	}
+	if player.Balance != 700 {
+		t.Errorf("balance: want 700 (credited once), got %d", player.Balance)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"SnakeGame/store"
+)
+
+// wishlistResponse builds the wishlist JSON; affordable compares each price to the current balance.
+func wishlistResponse() map[string]interface{} { // build the wishlist response
+	playerMu.RLock()
+	balance := player.Balance
+	playerMu.RUnlock()
+	entries := store.Wishlist()
+	items := make([]map[string]interface{}, len(entries))
+	for i, e := range entries {
+		items[i] = map[string]interface{}{
+			"itemId":        e.ItemID,
+			"name":          e.Name,
+			"price":         e.Price,
+			"originalPrice": e.OriginalPrice,
+			"onSale":        e.OnSale,
+			"affordable":    balance >= e.Price,
+			"addedAt":       e.AddedAt,
+		}
+	}
+	return map[string]interface{}{"items": items}
+}
+
+// GET /api/user/wishlist — list saved items with current prices
+func GetWishlistHandler(w http.ResponseWriter, r *http.Request) { // list the wishlist
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wishlistResponse())
+}
+
+// POST /api/user/wishlist — save a catalog item the player does not own
+func PostWishlistHandler(w http.ResponseWriter, r *http.Request) { // add an item to the wishlist
+	if r.Method != http.MethodPost {
+		return
+	}
+	var req struct { // request body for adding a wishlist item
+		ItemID string `json:"itemId"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.ItemID == "" {
+		writeValidationError(w, "invalid itemId")
+		return
+	}
+	allowCORS(w)
+	if err := store.AddToWishlist(req.ItemID, ownedSkins()); err != nil {
+		if errors.Is(err, store.ErrAlreadyWishlisted) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeValidationError(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wishlistResponse())
+}
+
+// DELETE /api/user/wishlist/{itemId} — remove a saved item
+func DeleteWishlistHandler(w http.ResponseWriter, r *http.Request) { // remove an item from the wishlist
+	if r.Method != http.MethodDelete {
+		return
+	}
+	allowCORS(w)
+	if err := store.RemoveFromWishlist(r.PathValue("itemId")); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wishlistResponse())
+}
+
+// POST /api/user/wishlist/{itemId}/cart — move a saved item into the cart
+func MoveWishlistToCartHandler(w http.ResponseWriter, r *http.Request) { // move a wishlist item to the cart
+	if r.Method != http.MethodPost {
+		return
+	}
+	allowCORS(w)
+	if err := store.MoveWishlistToCart(r.PathValue("itemId"), ownedSkins()); err != nil {
+		if errors.Is(err, store.ErrNotWishlisted) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeValidationError(w, err.Error())
+		return
+	}
+	writeCart(w, http.StatusOK)
+}
+
+// GET /api/user/notifications — wishlist sale and affordability notifications
+func GetNotificationsHandler(w http.ResponseWriter, r *http.Request) { // list notifications
+	if r.Method != http.MethodGet {
+		return
+	}
+	allowCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+	notifications := store.Notifications()
+	if notifications == nil {
+		notifications = []store.Notification{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"notifications": notifications})
+}
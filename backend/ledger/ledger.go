@@ -0,0 +1,69 @@
+package ledger
+
+import (
+	"sync"
+	"time"
+)
+
+// Accounts that do not belong to a player.
+const (
+	AccountEscrow   = "escrow"   // coins held while a trade settles
+	AccountHouse    = "house"    // fees kept by the game
+	AccountPayments = "payments" // coins bought with real money (coin packs)
+)
+
+// Entry types.
+const (
+	TypeEscrowHold    = "escrow.hold"    // buyer -> escrow
+	TypeEscrowRelease = "escrow.release" // escrow -> seller
+	TypeFee           = "fee"            // escrow -> house
+	TypeCoinPack      = "coinpack"       // payments -> buyer, ref is the order id
+)
+
+// Entry is one movement of coins between two accounts (player ids or the accounts above).
+type Entry struct {
+	ID        int       `json:"id"`
+	Type      string    `json:"type"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Amount    int       `json:"amount"`
+	Ref       string    `json:"ref"` // what the movement belongs to, e.g. a listing id
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var (
+	mu      sync.Mutex // protects entries
+	entries []Entry    // append-only, oldest first
+)
+
+// Record appends an entry and returns it.
+func Record(typ, from, to string, amount int, ref string) Entry {
+	mu.Lock()
+	defer mu.Unlock()
+	e := Entry{ID: len(entries) + 1, Type: typ, From: from, To: to, Amount: amount, Ref: ref, CreatedAt: time.Now()}
+	entries = append(entries, e)
+	return e
+}
+
+// Entries returns a copy of all entries, oldest first.
+func Entries() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Entry{}, entries...)
+}
+
+// Balance returns the coins an account received minus the coins it sent.
+func Balance(account string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	var total int
+	for _, e := range entries {
+		if e.To == account {
+			total += e.Amount
+		}
+		if e.From == account {
+			total -= e.Amount
+		}
+	}
+	return total
+}
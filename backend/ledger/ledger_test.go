@@ -0,0 +1,24 @@
+package ledger
+
+import "testing"
+
+func TestBalance_EscrowSettles(t *testing.T) {
+	Record(TypeEscrowHold, "buyer", AccountEscrow, 100, "L1")
+	if got := Balance(AccountEscrow); got != 100 {
+		t.Fatalf("escrow while held: want 100, got %d", got)
+	}
+	Record(TypeEscrowRelease, AccountEscrow, "seller", 95, "L1")
+	Record(TypeFee, AccountEscrow, AccountHouse, 5, "L1")
+	if got := Balance(AccountEscrow); got != 0 {
+		t.Errorf("escrow after settlement: want 0, got %d", got)
+	}
+	if got := Balance(AccountHouse); got != 5 {
+		t.Errorf("house: want 5, got %d", got)
+	}
+	if got := Balance("buyer"); got != -100 {
+		t.Errorf("buyer: want -100, got %d", got)
+	}
+	if n := len(Entries()); n != 3 {
+		t.Errorf("entries: want 3, got %d", n)
+	}
+}
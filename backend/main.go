@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"SnakeGame/breaker"
 	"SnakeGame/handlers"
+	"SnakeGame/payment"
+	"SnakeGame/store"
 )
 
 func main() {
@@ -14,21 +21,178 @@ func main() {
 	http.HandleFunc("/api/player", handlers.GetPlayerHandler) // get player information
 	http.HandleFunc("/api/earn", handlers.EarnCoinsHandler)   // earn coins
 	http.HandleFunc("/api/equip", handlers.EquipHandler)      // equip a skin
+	// Players: other players who can receive gifts
+	http.HandleFunc("POST /api/players", handlers.PostPlayersHandler)                // register a player
+	http.HandleFunc("GET /api/players/{id}/gifts", handlers.GetPlayerGiftsHandler)   // gifts a player received
+	http.HandleFunc("GET /api/players/{id}/events", handlers.GetPlayerEventsHandler) // live balance, skin, lives and cart updates (SSE)
+	// Coin packs: the only purchases paid with real money (skins and lives cost coins)
+	http.HandleFunc("POST /api/user/coin-packs/{id}/purchase", handlers.PostCoinPackPurchaseHandler) // buy coins with real money through the payment gateway
+	http.HandleFunc("GET /api/user/coin-packs/orders/{id}", handlers.GetCoinPackOrderHandler)        // order status (pending until the provider confirms)
+	http.HandleFunc("POST /api/payments/webhook", handlers.PaymentWebhookHandler)                    // signed provider events that settle pending orders
+	http.HandleFunc("GET /api/health", handlers.GetHealthHandler)                                    // server health and the payment circuit breaker state
+	// Marketplace: players sell skins to each other for coins (X-Player-ID selects the acting player)
+	http.HandleFunc("GET /api/market/listings", handlers.GetListingsHandler)           // active listings
+	http.HandleFunc("POST /api/market/listings", handlers.PostListingHandler)          // list an owned skin for sale
+	http.HandleFunc("DELETE /api/market/listings/{id}", handlers.DeleteListingHandler) // cancel a listing
+	http.HandleFunc("POST /api/market/listings/{id}/buy", handlers.BuyListingHandler)  // buy a listed skin
+	http.HandleFunc("GET /api/admin/ledger", handlers.GetLedgerHandler)                // coin movements and house fees
+	// Reconciliation: orders and ledger credits checked against the gateway's charges
+	http.HandleFunc("POST /api/admin/reconciliation", handlers.PostReconciliationHandler) // reconcile now
+	http.HandleFunc("GET /api/admin/reconciliation", handlers.GetReconciliationHandler)   // the most recent report
+	// Catalog
+	http.HandleFunc("GET /api/catalog", handlers.GetCatalogHandler) // list items with effective (sale) prices
+	// Scheduled sales: time-boxed price rules evaluated against the catalog
+	http.HandleFunc("POST /api/admin/price-rules", handlers.PostPriceRuleHandler)          // schedule a price rule
+	http.HandleFunc("GET /api/admin/price-rules", handlers.GetPriceRulesHandler)           // list price rules
+	http.HandleFunc("DELETE /api/admin/price-rules/{id}", handlers.DeletePriceRuleHandler) // cancel a price rule
+	// Limited-edition stock
+	http.HandleFunc("PUT /api/admin/stock/{id}", handlers.PutStockHandler)       // limit an item to a global stock count
+	http.HandleFunc("DELETE /api/admin/stock/{id}", handlers.DeleteStockHandler) // remove the stock limit
+	// Wishlist
+	http.HandleFunc("GET /api/user/wishlist", handlers.GetWishlistHandler)                       // list saved items with current prices
+	http.HandleFunc("POST /api/user/wishlist", handlers.PostWishlistHandler)                     // save an item that is not owned
+	http.HandleFunc("DELETE /api/user/wishlist/{itemId}", handlers.DeleteWishlistHandler)        // remove a saved item
+	http.HandleFunc("POST /api/user/wishlist/{itemId}/cart", handlers.MoveWishlistToCartHandler) // move a saved item into the cart
+	http.HandleFunc("GET /api/user/notifications", handlers.GetNotificationsHandler)             // sale and affordability notifications
 	// Requirement: cart API
 	http.HandleFunc("POST /api/user/cart/items", handlers.PostCartItemsHandler) // add an item to the cart
 	http.HandleFunc("GET /api/user/cart", handlers.GetCartHandler)              // get the cart
 	http.HandleFunc("/api/user/cart/items/{id}", handlers.CartItemsIDHandler)   // update an item (e.g. change quantity)
-	http.HandleFunc("POST /api/user/orders", handlers.CheckoutHandler)          // process the cart: only charges for items the player does not already own (skins already in OwnedSkins are skipped). Prevents deducting coins for duplicate skins. Uses Idempotency-Key header: repeated requests with the same key within 24 hours receive the cached response without re-processing. Set header X-Simulate-Payment-Timeout: true to simulate gateway timeout (for testing retry).
+	http.HandleFunc("POST /api/user/orders", handlers.CheckoutHandler)          // process the cart: only charges for items the player does not already own (skins already in OwnedSkins are skipped). Prevents deducting coins for duplicate skins. Uses Idempotency-Key header: repeated requests with the same key within 24 hours receive the cached response without re-processing.
+	http.HandleFunc("POST /api/user/purchases", handlers.PostPurchaseHandler)   // buy one item right away, leaving the cart untouched
+	http.HandleFunc("GET /api/user/cart/quote", handlers.GetCartQuoteHandler)   // price the cart exactly as checkout would
+	http.HandleFunc("PUT /api/user/cart", handlers.PutCartHandler)              // replace the whole cart atomically
+	http.HandleFunc("POST /api/user/cart/batch", handlers.PostCartBatchHandler) // apply add/update/remove operations all-or-nothing
+	// Guest carts: merged into the account cart on sign-in
+	http.HandleFunc("POST /api/guest/carts", handlers.PostGuestCartHandler)                      // start a guest cart
+	http.HandleFunc("GET /api/guest/carts/{guestId}", handlers.GetGuestCartHandler)              // view a guest cart
+	http.HandleFunc("POST /api/guest/carts/{guestId}/items", handlers.PostGuestCartItemsHandler) // add an item to a guest cart
+	http.HandleFunc("POST /api/user/cart/merge", handlers.MergeGuestCartHandler)                 // merge a guest cart on sign-in
+	// Promo codes
+	http.HandleFunc("POST /api/user/cart/coupon", handlers.PostCartCouponHandler)     // attach a promo code to the cart
+	http.HandleFunc("DELETE /api/user/cart/coupon", handlers.DeleteCartCouponHandler) // remove the attached promo code
+	http.HandleFunc("POST /api/admin/coupons", handlers.PostCouponHandler)            // create a promo code
+	http.HandleFunc("GET /api/admin/coupons", handlers.GetCouponsHandler)             // list promo codes with redemption counts
 	// Legacy routes (backward compatible)
 	http.HandleFunc("GET /api/cart", handlers.GetCartHandler)           // get the cart
 	http.HandleFunc("/api/cart", handlers.CartHandler)                  // add an item to the cart
 	http.HandleFunc("/api/cart/remove", handlers.RemoveCartItemHandler) // remove an item from the cart
-	http.HandleFunc("/api/checkout", handlers.CheckoutHandler)          // process the cart: only charges for items the player does not already own (skins already in OwnedSkins are skipped). Prevents deducting coins for duplicate skins. Uses Idempotency-Key header: repeated requests with the same key within 24 hours receive the cached response without re-processing. Set header X-Simulate-Payment-Timeout: true to simulate gateway timeout (for testing retry).
+	http.HandleFunc("/api/checkout", handlers.CheckoutHandler)          // process the cart: only charges for items the player does not already own (skins already in OwnedSkins are skipped). Prevents deducting coins for duplicate skins. Uses Idempotency-Key header: repeated requests with the same key within 24 hours receive the cached response without re-processing.
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
+	// PAYMENT_PROVIDER_URL selects a REST payment provider for coin packs; "fake" serves the
+	// in-process fake provider under /fake-provider/ for local end-to-end runs. Unset keeps the stub.
+	var gw payment.Gateway = &payment.StubGateway{}
+	if providerURL := os.Getenv("PAYMENT_PROVIDER_URL"); providerURL != "" {
+		gw = providerGateway(providerURL, os.Getenv("PAYMENT_PROVIDER_KEY"), "/fake-provider", port)
+	}
+	// PAYMENT_SECONDARY_URL adds a second provider (same "fake" option). PAYMENT_ROUTING splits charges
+	// between them: failover (default), weighted (PAYMENT_PRIMARY_WEIGHT percent to the primary,
+	// default 50) or amount (charges of PAYMENT_ROUTING_THRESHOLD_CENTS or more to the secondary;
+	// required). Invalid values stop the server rather than silently routing everything to one side.
+	if secondaryURL := os.Getenv("PAYMENT_SECONDARY_URL"); secondaryURL != "" {
+		policy := payment.Policy(os.Getenv("PAYMENT_ROUTING"))
+		switch policy {
+		case "":
+			policy = payment.PolicyFailover
+		case payment.PolicyFailover, payment.PolicyWeighted, payment.PolicyAmount:
+		default:
+			log.Fatalf("unknown PAYMENT_ROUTING %q", policy)
+		}
+		weight := 50
+		if v := os.Getenv("PAYMENT_PRIMARY_WEIGHT"); v != "" {
+			var err error
+			if weight, err = strconv.Atoi(v); err != nil || weight < 0 || weight > 100 {
+				log.Fatalf("PAYMENT_PRIMARY_WEIGHT must be a percentage between 0 and 100, got %q", v)
+			}
+		}
+		threshold := 0
+		if v := os.Getenv("PAYMENT_ROUTING_THRESHOLD_CENTS"); v != "" || policy == payment.PolicyAmount {
+			var err error
+			if threshold, err = strconv.Atoi(v); err != nil || threshold <= 0 {
+				log.Fatalf("PAYMENT_ROUTING_THRESHOLD_CENTS must be a positive number of cents, got %q", v)
+			}
+		}
+		gw = &payment.Router{
+			Primary:              payment.Route{Name: "primary", Gateway: gw},
+			Secondary:            payment.Route{Name: "secondary", Gateway: providerGateway(secondaryURL, os.Getenv("PAYMENT_SECONDARY_KEY"), "/fake-provider-secondary", port)},
+			Policy:               policy,
+			PrimaryWeight:        weight,
+			AmountThresholdCents: threshold,
+		}
+	}
+	handlers.SetPaymentGateway(gw)
+	// PAYMENT_FAULT_INJECTION=true (test environments only) wraps the gateway in a fault injector that
+	// QA configures through /api/test/payment-faults, e.g. to make a purchase succeed on its third attempt.
+	if os.Getenv("PAYMENT_FAULT_INJECTION") == "true" {
+		handlers.EnablePaymentFaults()
+		http.HandleFunc("GET /api/test/payment-faults", handlers.GetPaymentFaultsHandler)       // injected faults and stats
+		http.HandleFunc("PUT /api/test/payment-faults", handlers.PutPaymentFaultsHandler)       // script errors, error rate and latency
+		http.HandleFunc("DELETE /api/test/payment-faults", handlers.DeletePaymentFaultsHandler) // pass every charge through again
+		log.Println("payment fault injection enabled")
+	}
+	// PAYMENT_BREAKER_THRESHOLD and PAYMENT_BREAKER_COOLDOWN tune the gateway's circuit breaker; an unset
+	// value keeps its default, and an invalid one stops the server rather than silently using the default.
+	if os.Getenv("PAYMENT_BREAKER_THRESHOLD") != "" || os.Getenv("PAYMENT_BREAKER_COOLDOWN") != "" {
+		var cfg breaker.Config
+		if v := os.Getenv("PAYMENT_BREAKER_THRESHOLD"); v != "" { // consecutive gateway outages that open the breaker
+			var err error
+			if cfg.FailureThreshold, err = strconv.Atoi(v); err != nil || cfg.FailureThreshold <= 0 {
+				log.Fatalf("PAYMENT_BREAKER_THRESHOLD must be a positive number of outages, got %q", v)
+			}
+		}
+		if v := os.Getenv("PAYMENT_BREAKER_COOLDOWN"); v != "" { // time open before a probe charge is let through
+			var err error
+			if cfg.Cooldown, err = time.ParseDuration(v); err != nil || cfg.Cooldown <= 0 {
+				log.Fatalf("PAYMENT_BREAKER_COOLDOWN must be a positive duration such as 30s, got %q", v)
+			}
+		}
+		handlers.SetPaymentBreaker(cfg)
+	}
+	handlers.SetWebhookSecret(os.Getenv("PAYMENT_WEBHOOK_SECRET")) // HMAC secret for provider webhooks; unset rejects them
+	if n, err := strconv.Atoi(os.Getenv("CART_MAX_CONSUMABLES")); err == nil {
+		store.SetMaxConsumableQuantity(n) // per-line cap for consumables such as extra lives
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("CART_TTL")); err == nil {
+		store.SetCartTTL(ttl) // idle time after the last change before a cart is discarded
+	}
+	if n, err := strconv.Atoi(os.Getenv("MARKET_FEE_PERCENT")); err == nil {
+		handlers.SetMarketFeePercent(n) // house fee taken from each marketplace sale
+	}
+	reportPath := os.Getenv("RECONCILE_REPORT")
+	if reportPath == "" {
+		reportPath = "reconciliation-report.json"
+	}
+	handlers.SetReconcileReportPath(reportPath) // every reconciliation report is also written here
+	if interval, err := time.ParseDuration(os.Getenv("RECONCILE_INTERVAL")); err == nil && interval > 0 {
+		handlers.StartReconciliation(context.Background(), interval) // scheduled reconciliation job
+	}
+	store.OnEvent(func(e store.Event) { // abandoned-cart analytics hook
+		if e.Type == store.EventCartExpired {
+			log.Printf("cart expired: %d lines, %d coins, last modified %s", len(e.Items), e.Total, e.LastModified.Format(time.RFC3339))
+		}
+	})
+	store.StartExpiry(context.Background(), time.Minute)          // background job that expires idle carts
+	handlers.StartLiveCart(context.Background())                  // pushes cart changes to open event streams
+	store.StartWishlistWatcher(context.Background(), time.Minute) // notifies when scheduled sales start on wishlisted items
+
 	fmt.Printf("Snake server running at http://localhost:%s\n", port)
 	http.ListenAndServe(":"+port, nil)
 }
+
+// providerGateway returns an HTTP gateway for a payment provider. The URL "fake" mounts an in-process
+// fake provider under mount and points the gateway at it.
+func providerGateway(providerURL, apiKey, mount, port string) payment.Gateway {
+	if providerURL == "fake" {
+		http.Handle(mount+"/", http.StripPrefix(mount, payment.NewFakeProvider()))
+		providerURL = "http://localhost:" + port + mount
+	}
+	return &payment.HTTPGateway{
+		BaseURL: providerURL,
+		APIKey:  apiKey,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
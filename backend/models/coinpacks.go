@@ -0,0 +1,41 @@
+package models
+
+import "sort"
+
+// CoinPack is a bundle of coins sold for real money. Packs are priced in the smallest currency unit
+// and are never part of the coin catalog: they cannot be added to a cart or bought with coins.
+type CoinPack struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Coins      int      `json:"coins"`      // coins credited to the balance
+	PriceCents int      `json:"priceCents"` // amount charged through the payment gateway
+	Currency   string   `json:"currency"`
+	Kind       ItemKind `json:"kind"`
+}
+
+// CoinPacks catalog: id -> CoinPack. Protected by mu.
+var CoinPacks = map[string]CoinPack{
+	"coins_500":  {ID: "coins_500", Name: "Pouch of Coins", Coins: 500, PriceCents: 499, Currency: "USD", Kind: ItemKindCoinPack},
+	"coins_1200": {ID: "coins_1200", Name: "Bag of Coins", Coins: 1200, PriceCents: 999, Currency: "USD", Kind: ItemKindCoinPack},
+	"coins_3000": {ID: "coins_3000", Name: "Chest of Coins", Coins: 3000, PriceCents: 1999, Currency: "USD", Kind: ItemKindCoinPack},
+}
+
+// CoinPackByID returns a coin pack. Second return is false if not found.
+func CoinPackByID(id string) (CoinPack, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := CoinPacks[id]
+	return p, ok
+}
+
+// AllCoinPacks returns a copy of all coin packs, cheapest first.
+func AllCoinPacks() []CoinPack {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]CoinPack, 0, len(CoinPacks))
+	for _, p := range CoinPacks {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PriceCents < out[j].PriceCents })
+	return out
+}
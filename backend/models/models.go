@@ -22,6 +22,7 @@ type ItemKind int
 const (
 	ItemKindSkin ItemKind = iota // item kind skin
 	ItemKindLife // item kind life
+	ItemKindCoinPack // item kind coin pack (bought with real money, never with coins)
 )
 
 // Item is a generic purchasable (skin or life item) for catalog lookups.
@@ -32,18 +33,20 @@ type Item struct {
 	Kind  ItemKind `json:"kind"`
 }
 
-// CartItem is a single line in the cart (unique line id, item id, display name, price, quantity, kind).
+// CartItem is a single line in the cart (unique line id, item id, display name, price, list price, quantity, kind).
+// Price is the effective price when the line was added; OriginalPrice is the catalog list price at that time.
 type CartItem struct {
-	ID       string   `json:"id"`
-	ItemID   string   `json:"itemId"`
-	Name     string   `json:"name"`
-	Price    int      `json:"price"`
-	Quantity int      `json:"quantity"`
-	Kind     ItemKind `json:"kind"`
+	ID            string   `json:"id"`
+	ItemID        string   `json:"itemId"`
+	Name          string   `json:"name"`
+	Price         int      `json:"price"`
+	OriginalPrice int      `json:"originalPrice"`
+	Quantity      int      `json:"quantity"`
+	Kind          ItemKind `json:"kind"`
 }
 
 var (
-	mu sync.RWMutex // protects the catalog maps
+	mu sync.RWMutex // protects the catalog maps and price rules
 
 	// Skins catalog: id -> Skin
 	Skins = map[string]Skin{ // default skin, gold skin, rainbow skin, ice skin, fire skin
@@ -60,7 +63,7 @@ var (
 	}
 )
 
-// SkinPrice returns the price for a skin by id. Second return is false if not found.
+// SkinPrice returns the list price for a skin by id (ignores price rules). Second return is false if not found.
 func SkinPrice(id string) (int, bool) {
 	mu.RLock()
 	defer mu.RUnlock()
@@ -68,7 +71,7 @@ func SkinPrice(id string) (int, bool) {
 	return s.Price, ok
 }
 
-// LifeItemPrice returns the price for a life item by id. Second return is false if not found.
+// LifeItemPrice returns the list price for a life item by id (ignores price rules). Second return is false if not found.
 func LifeItemPrice(id string) (int, bool) {
 	mu.RLock()
 	defer mu.RUnlock()
@@ -76,12 +79,10 @@ func LifeItemPrice(id string) (int, bool) {
 	return item.Price, ok
 }
 
-// ItemPrice returns the price for any item (skin or life) by id. Second return is false if not found.
+// ItemPrice returns the effective price (after active price rules) for any item by id. Second return is false if not found.
 func ItemPrice(id string) (int, bool) {
-	if p, ok := SkinPrice(id); ok {
-		return p, true
-	}
-	return LifeItemPrice(id)
+	p, ok := ItemPricing(id)
+	return p.Effective, ok
 }
 
 // IsSkin returns true if id is a known skin.
@@ -122,15 +123,16 @@ func AllLifeItems() []LifeItem {
 	return out
 }
 
-// ItemDisplay returns name, price, and kind for a catalog item by id. ok is false if unknown.
+// ItemDisplay returns name, effective price (after active price rules), and kind for a catalog item by id. ok is false if unknown.
 func ItemDisplay(id string) (name string, price int, kind ItemKind, ok bool) {
 	mu.RLock()
 	defer mu.RUnlock()
+	t := now()
 	if s, okSkin := Skins[id]; okSkin {
-		return s.Name, s.Price, ItemKindSkin, true
+		return s.Name, priceAt(id, s.Price, ItemKindSkin, t).Effective, ItemKindSkin, true
 	}
 	if l, okLife := LifeItems[id]; okLife {
-		return l.Name, l.Price, ItemKindLife, true
+		return l.Name, priceAt(id, l.Price, ItemKindLife, t).Effective, ItemKindLife, true
 	}
 	return "", 0, 0, false
 }
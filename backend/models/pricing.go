@@ -0,0 +1,221 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrInvalidPriceRule is returned when a price rule is malformed (no discount, bad window, etc.).
+var ErrInvalidPriceRule = errors.New("invalid price rule")
+
+// ErrPriceRuleNotFound is returned when a price rule id is not found.
+var ErrPriceRuleNotFound = errors.New("price rule not found")
+
+// PriceRule is a time-boxed price change (a sale). It applies to the listed ItemIDs, or to every
+// item of Kind when ItemIDs is empty, or to the whole catalog when both are empty. Exactly one of
+// PercentOff or SalePrice must be set. The rule is active for StartsAt <= now < EndsAt.
+type PriceRule struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name,omitempty"`
+	ItemIDs    []string  `json:"itemIds,omitempty"`
+	Kind       *ItemKind `json:"kind,omitempty"`
+	PercentOff int       `json:"percentOff,omitempty"`
+	SalePrice  *int      `json:"salePrice,omitempty"`
+	StartsAt   time.Time `json:"startsAt"`
+	EndsAt     time.Time `json:"endsAt"`
+}
+
+// Price is the list price and the effective (sale) price of an item at a point in time.
+type Price struct {
+	Original  int        // catalog list price
+	Effective int        // price after the best active rule
+	RuleID    string     // id of the rule that produced Effective ("" when not on sale)
+	EndsAt    *time.Time // when the applied rule ends (nil when not on sale)
+}
+
+// Discount returns how many coins the active rule takes off the list price.
+func (p Price) Discount() int { return p.Original - p.Effective }
+
+// OnSale returns true if an active rule lowers the price.
+func (p Price) OnSale() bool { return p.Effective < p.Original }
+
+var (
+	priceRules []PriceRule // protected by mu
+	now        = time.Now  // clock used for rule evaluation; replaced in tests
+)
+
+func newPriceRuleID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "rule_" + hex.EncodeToString(b)
+}
+
+// AddPriceRule validates and stores a price rule. StartsAt defaults to now. Returns the stored rule.
+func AddPriceRule(r PriceRule) (PriceRule, error) {
+	if (r.PercentOff == 0) == (r.SalePrice == nil) {
+		return PriceRule{}, errors.Join(ErrInvalidPriceRule, errors.New("exactly one of percentOff or salePrice is required"))
+	}
+	if r.PercentOff < 0 || r.PercentOff > 100 {
+		return PriceRule{}, errors.Join(ErrInvalidPriceRule, errors.New("percentOff must be between 1 and 100"))
+	}
+	if r.SalePrice != nil && *r.SalePrice < 0 {
+		return PriceRule{}, errors.Join(ErrInvalidPriceRule, errors.New("salePrice must not be negative"))
+	}
+	if r.StartsAt.IsZero() {
+		r.StartsAt = now()
+	}
+	if r.EndsAt.IsZero() || !r.EndsAt.After(r.StartsAt) {
+		return PriceRule{}, errors.Join(ErrInvalidPriceRule, errors.New("endsAt must be after startsAt"))
+	}
+	r.ID = newPriceRuleID()
+	r.ItemIDs = append([]string(nil), r.ItemIDs...)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range r.ItemIDs {
+		if !inCatalog(id) {
+			return PriceRule{}, errors.Join(ErrInvalidPriceRule, errors.New("unknown item "+id))
+		}
+	}
+	priceRules = append(priceRules, r)
+	return r, nil
+}
+
+// RemovePriceRule deletes the price rule with the given id.
+func RemovePriceRule(id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range priceRules {
+		if priceRules[i].ID == id {
+			priceRules = append(priceRules[:i], priceRules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrPriceRuleNotFound
+}
+
+// PriceRules returns a copy of all stored rules (active, scheduled and expired), ordered by start time.
+func PriceRules() []PriceRule {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]PriceRule, len(priceRules))
+	copy(out, priceRules)
+	sort.Slice(out, func(i, j int) bool { return out[i].StartsAt.Before(out[j].StartsAt) })
+	return out
+}
+
+// active reports whether the rule applies to the item at time t.
+func (r PriceRule) active(itemID string, kind ItemKind, t time.Time) bool {
+	if t.Before(r.StartsAt) || !t.Before(r.EndsAt) {
+		return false
+	}
+	if len(r.ItemIDs) > 0 {
+		for _, id := range r.ItemIDs {
+			if id == itemID {
+				return true
+			}
+		}
+		return false
+	}
+	return r.Kind == nil || *r.Kind == kind
+}
+
+// apply returns the price after the rule for the given list price (never below 0 or above list).
+func (r PriceRule) apply(list int) int {
+	p := list
+	if r.SalePrice != nil {
+		p = *r.SalePrice
+	} else if r.PercentOff > 0 {
+		p = list - list*r.PercentOff/100
+	}
+	if p < 0 {
+		p = 0
+	}
+	if p > list {
+		p = list
+	}
+	return p
+}
+
+// inCatalog reports whether id is a skin or life item. Callers hold mu.
+func inCatalog(id string) bool {
+	_, okSkin := Skins[id]
+	_, okLife := LifeItems[id]
+	return okSkin || okLife
+}
+
+// priceAt computes the effective price for an item at time t. Callers hold mu.
+// When several rules are active the lowest resulting price wins.
+func priceAt(id string, list int, kind ItemKind, t time.Time) Price {
+	p := Price{Original: list, Effective: list}
+	for _, r := range priceRules {
+		if !r.active(id, kind, t) {
+			continue
+		}
+		if sale := r.apply(list); sale < p.Effective {
+			ends := r.EndsAt
+			p.Effective, p.RuleID, p.EndsAt = sale, r.ID, &ends
+		}
+	}
+	return p
+}
+
+// ItemPricing returns the list and effective price of a catalog item right now. ok is false if unknown.
+func ItemPricing(id string) (Price, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if s, ok := Skins[id]; ok {
+		return priceAt(id, s.Price, ItemKindSkin, now()), true
+	}
+	if l, ok := LifeItems[id]; ok {
+		return priceAt(id, l.Price, ItemKindLife, now()), true
+	}
+	return Price{}, false
+}
+
+// CatalogEntry is one purchasable item with its current pricing (for API listing).
+type CatalogEntry struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Kind          ItemKind   `json:"kind"`
+	Price         int        `json:"price"`
+	OriginalPrice int        `json:"originalPrice"`
+	Discount      int        `json:"discount"`
+	SaleEndsAt    *time.Time `json:"saleEndsAt,omitempty"`
+	Stock         *int       `json:"stock,omitempty"` // remaining units for limited items (nil when unlimited)
+}
+
+// Catalog returns every skin and life item with effective prices and remaining stock, ordered by kind then id.
+func Catalog() []CatalogEntry {
+	mu.RLock()
+	defer mu.RUnlock()
+	t := now()
+	out := make([]CatalogEntry, 0, len(Skins)+len(LifeItems))
+	add := func(id, name string, list int, kind ItemKind) {
+		p := priceAt(id, list, kind, t)
+		e := CatalogEntry{
+			ID: id, Name: name, Kind: kind, Price: p.Effective,
+			OriginalPrice: p.Original, Discount: p.Discount(), SaleEndsAt: p.EndsAt,
+		}
+		if s, limited := stock[id]; limited {
+			remaining := s.level().Remaining
+			e.Stock = &remaining
+		}
+		out = append(out, e)
+	}
+	for id, s := range Skins {
+		add(id, s.Name, s.Price, ItemKindSkin)
+	}
+	for id, l := range LifeItems {
+		add(id, l.Name, l.Price, ItemKindLife)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// withClock pins the pricing clock and clears rules for the duration of a test.
+func withClock(t *testing.T, at time.Time) *time.Time {
+	t.Helper()
+	clock := at
+	prevNow, prevRules := now, priceRules
+	now = func() time.Time { return clock }
+	priceRules = nil
+	t.Cleanup(func() { now, priceRules = prevNow, prevRules })
+	return &clock
+}
+
+func TestPriceRule_ActiveWindow(t *testing.T) {
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	clock := withClock(t, start.Add(-time.Hour))
+	skin := ItemKindSkin
+	if _, err := AddPriceRule(PriceRule{Kind: &skin, PercentOff: 30, StartsAt: start, EndsAt: start.Add(48 * time.Hour)}); err != nil {
+		t.Fatalf("AddPriceRule: %v", err)
+	}
+
+	if p, _ := ItemPrice("skin_gold"); p != 100 {
+		t.Errorf("before start: want 100, got %d", p)
+	}
+	*clock = start
+	if p, _ := ItemPrice("skin_gold"); p != 70 {
+		t.Errorf("during sale: want 70, got %d", p)
+	}
+	if p, _ := ItemPrice("extra_life"); p != 50 {
+		t.Errorf("life items are not skins: want 50, got %d", p)
+	}
+	*clock = start.Add(48 * time.Hour)
+	if p, _ := ItemPrice("skin_gold"); p != 100 {
+		t.Errorf("after end: want 100, got %d", p)
+	}
+}
+
+func TestPriceRule_LowestPriceWins(t *testing.T) {
+	at := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	withClock(t, at)
+	fifty := 50
+	AddPriceRule(PriceRule{PercentOff: 30, EndsAt: at.Add(time.Hour)})
+	fire, err := AddPriceRule(PriceRule{ItemIDs: []string{"skin_fire"}, SalePrice: &fifty, EndsAt: at.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("AddPriceRule: %v", err)
+	}
+
+	p, ok := ItemPricing("skin_fire")
+	if !ok {
+		t.Fatal("skin_fire should be in the catalog")
+	}
+	if p.Effective != 50 || p.Original != 100 || p.Discount() != 50 || p.RuleID != fire.ID {
+		t.Errorf("unexpected pricing %+v", p)
+	}
+	if _, price, _, _ := ItemDisplay("skin_ice"); price != 70 {
+		t.Errorf("skin_ice: want 70 from the catalog-wide rule, got %d", price)
+	}
+}
+
+func TestAddPriceRule_Validation(t *testing.T) {
+	at := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	withClock(t, at)
+	ten := 10
+	cases := []PriceRule{
+		{EndsAt: at.Add(time.Hour)},                                  // no discount
+		{PercentOff: 10, SalePrice: &ten, EndsAt: at.Add(time.Hour)}, // both discounts
+		{PercentOff: 120, EndsAt: at.Add(time.Hour)},                 // percent out of range
+		{PercentOff: 10}, // no end
+		{PercentOff: 10, StartsAt: at, EndsAt: at}, // empty window
+		{PercentOff: 10, ItemIDs: []string{"nope"}, EndsAt: at.Add(time.Hour)},
+	}
+	for i, c := range cases {
+		if _, err := AddPriceRule(c); !errors.Is(err, ErrInvalidPriceRule) {
+			t.Errorf("case %d: want ErrInvalidPriceRule, got %v", i, err)
+		}
+	}
+	if len(PriceRules()) != 0 {
+		t.Error("invalid rules must not be stored")
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSoldOut is returned when a limited item has no stock left for the requested quantity.
+var ErrSoldOut = errors.New("item sold out")
+
+// ErrUnknownItem is returned when setting stock for an item that is not in the catalog.
+var ErrUnknownItem = errors.New("unknown item")
+
+// StockLevel is the global stock of a limited-edition item. Remaining = Total - Sold - Reserved.
+type StockLevel struct {
+	ItemID    string `json:"itemId"`
+	Total     int    `json:"total"`
+	Sold      int    `json:"sold"`
+	Reserved  int    `json:"reserved"`  // units held by checkouts that have not completed yet
+	Remaining int    `json:"remaining"` // units still available to new carts and checkouts
+}
+
+// stock holds limited items only; items without an entry have unlimited stock. Protected by mu.
+var stock = map[string]*StockLevel{}
+
+// SetStock limits an item to total units ever sold. Units already sold or reserved still count.
+func SetStock(id string, total int) (StockLevel, error) {
+	if total < 0 {
+		return StockLevel{}, errors.New("total must not be negative")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !inCatalog(id) {
+		return StockLevel{}, ErrUnknownItem
+	}
+	s, ok := stock[id]
+	if !ok {
+		s = &StockLevel{ItemID: id}
+		stock[id] = s
+	}
+	s.Total = total
+	return s.level(), nil
+}
+
+// ClearStock removes the limit so the item is unlimited again.
+func ClearStock(id string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(stock, id)
+}
+
+// level returns a copy with Remaining filled in. Callers hold mu.
+func (s *StockLevel) level() StockLevel {
+	out := *s
+	out.Remaining = s.Total - s.Sold - s.Reserved
+	if out.Remaining < 0 {
+		out.Remaining = 0
+	}
+	return out
+}
+
+// Stock returns the stock level of a limited item. limited is false for unlimited items.
+func Stock(id string) (level StockLevel, limited bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := stock[id]
+	if !ok {
+		return StockLevel{}, false
+	}
+	return s.level(), true
+}
+
+// ReserveStock holds units for a checkout, all or nothing: if any limited item lacks stock for
+// its quantity, nothing is reserved and an error wrapping ErrSoldOut is returned.
+// Pair with CommitStock on success or ReleaseStock on failure.
+func ReserveStock(quantities map[string]int) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for id, qty := range quantities {
+		if s, ok := stock[id]; ok && s.level().Remaining < qty {
+			return fmt.Errorf("%w: %s", ErrSoldOut, id)
+		}
+	}
+	for id, qty := range quantities {
+		if s, ok := stock[id]; ok {
+			s.Reserved += qty
+		}
+	}
+	return nil
+}
+
+// CommitStock turns reserved units into sold units after a completed purchase.
+func CommitStock(quantities map[string]int) {
+	mu.Lock()
+	defer mu.Unlock()
+	for id, qty := range quantities {
+		if s, ok := stock[id]; ok {
+			s.Reserved -= qty
+			s.Sold += qty
+		}
+	}
+}
+
+// ReleaseStock returns reserved units after a failed checkout.
+func ReleaseStock(quantities map[string]int) {
+	mu.Lock()
+	defer mu.Unlock()
+	for id, qty := range quantities {
+		if s, ok := stock[id]; ok {
+			s.Reserved -= qty
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestReserveStock_NeverOversells(t *testing.T) {
+	if _, err := SetStock("skin_rainbow", 1); err != nil {
+		t.Fatalf("SetStock: %v", err)
+	}
+	t.Cleanup(func() { ClearStock("skin_rainbow") })
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ReserveStock(map[string]int{"skin_rainbow": 1}) == nil {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if won != 1 {
+		t.Fatalf("want exactly 1 reservation for the last unit, got %d", won)
+	}
+	CommitStock(map[string]int{"skin_rainbow": 1})
+	level, limited := Stock("skin_rainbow")
+	if !limited || level.Sold != 1 || level.Reserved != 0 || level.Remaining != 0 {
+		t.Errorf("unexpected level after commit: %+v", level)
+	}
+}
+
+func TestReserveStock_AllOrNothing(t *testing.T) {
+	SetStock("skin_ice", 2)
+	SetStock("skin_fire", 1)
+	t.Cleanup(func() { ClearStock("skin_ice"); ClearStock("skin_fire") })
+
+	err := ReserveStock(map[string]int{"skin_ice": 1, "skin_fire": 2, "extra_life": 10})
+	if !errors.Is(err, ErrSoldOut) {
+		t.Fatalf("want ErrSoldOut, got %v", err)
+	}
+	if level, _ := Stock("skin_ice"); level.Reserved != 0 {
+		t.Errorf("failed reservation must not hold other items, got %+v", level)
+	}
+	if err := ReserveStock(map[string]int{"skin_ice": 2}); err != nil {
+		t.Fatalf("ReserveStock: %v", err)
+	}
+	ReleaseStock(map[string]int{"skin_ice": 2})
+	if level, _ := Stock("skin_ice"); level.Remaining != 2 {
+		t.Errorf("release should restore stock, got %+v", level)
+	}
+}
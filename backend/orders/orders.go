@@ -0,0 +1,177 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when an order id or payment key is unknown.
+var ErrNotFound = errors.New("order not found")
+
+// ErrDuplicatePaymentKey is returned by Create when a pending or succeeded order already uses the
+// payment key.
+var ErrDuplicatePaymentKey = errors.New("an order with this payment key already exists")
+
+// ErrInvalidTransition is returned when an order cannot move to the requested status.
+var ErrInvalidTransition = errors.New("invalid order transition")
+
+// Status is the payment state of an order.
+type Status string
+
+// Order statuses. Pending is the only non-final status.
+const (
+	StatusPending   Status = "pending"   // charge accepted, outcome not confirmed yet; items withheld
+	StatusSucceeded Status = "succeeded" // paid and fulfilled
+	StatusFailed    Status = "failed"    // payment failed; nothing delivered
+)
+
+// PaymentState is the state of an order's card authorization at the gateway.
+type PaymentState string
+
+// Payment states. The amount is held when authorized and only taken when captured, after the order
+// has been fulfilled; an order that cannot be fulfilled has its authorization voided.
+const (
+	PaymentAuthorized PaymentState = "authorized"
+	PaymentCaptured   PaymentState = "captured"
+	PaymentVoided     PaymentState = "voided"
+)
+
+// transitions lists the statuses each status may move to.
+var transitions = map[Status][]Status{
+	StatusPending: {StatusSucceeded, StatusFailed},
+}
+
+// Order is a real-money purchase (a coin pack) and its payment state.
+type Order struct {
+	ID            string       `json:"id"`
+	PlayerID      string       `json:"playerId"`
+	PackID        string       `json:"packId"`
+	Coins         int          `json:"coins"` // coins credited when the order succeeds
+	AmountCents   int          `json:"amountCents"`
+	Currency      string       `json:"currency"`
+	PaymentKey    string       `json:"paymentKey"`              // idempotency key sent to the gateway
+	Gateway       string       `json:"gateway,omitempty"`       // gateway the charge was routed to, when several are configured
+	TransactionID string       `json:"transactionId,omitempty"` // gateway authorization id
+	PaymentState  PaymentState `json:"paymentState,omitempty"`
+	Status        Status       `json:"status"`
+	FailureReason string       `json:"failureReason,omitempty"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	UpdatedAt     time.Time    `json:"updatedAt"`
+}
+
+var (
+	mu     sync.Mutex         // protects orders and byKey
+	orders []*Order           // oldest first
+	byKey  = map[string]int{} // payment key -> index in orders
+)
+
+// Create stores a new pending order and returns it with its id. A payment key belongs to one live
+// order: if a pending or succeeded order already uses o.PaymentKey, Create returns that order and
+// ErrDuplicatePaymentKey, so the same payment can never be fulfilled twice. A failed order's key may
+// be reused by a retry.
+func Create(o Order) (Order, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if i, ok := byKey[o.PaymentKey]; ok && o.PaymentKey != "" && orders[i].Status != StatusFailed {
+		return *orders[i], ErrDuplicatePaymentKey
+	}
+	o.ID = fmt.Sprintf("ord_%d", len(orders)+1)
+	o.Status = StatusPending
+	o.CreatedAt = time.Now()
+	o.UpdatedAt = o.CreatedAt
+	orders = append(orders, &o)
+	if o.PaymentKey != "" {
+		byKey[o.PaymentKey] = len(orders) - 1
+	}
+	return o, nil
+}
+
+// Get returns an order by id.
+func Get(id string) (Order, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, o := range orders {
+		if o.ID == id {
+			return *o, nil
+		}
+	}
+	return Order{}, ErrNotFound
+}
+
+// ByPaymentKey returns the order charged with the given gateway idempotency key.
+func ByPaymentKey(key string) (Order, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	i, ok := byKey[key]
+	if !ok {
+		return Order{}, ErrNotFound
+	}
+	return *orders[i], nil
+}
+
+// List returns a copy of all orders, oldest first.
+func List() []Order {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]Order, len(orders))
+	for i, o := range orders {
+		out[i] = *o
+	}
+	return out
+}
+
+// Transition moves an order to status to (with reason for failures). Only pending orders can
+// change, so an order is fulfilled or failed exactly once; anything else returns ErrInvalidTransition.
+func Transition(id string, to Status, reason string) (Order, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, o := range orders {
+		if o.ID != id {
+			continue
+		}
+		for _, allowed := range transitions[o.Status] {
+			if allowed == to {
+				o.Status = to
+				o.FailureReason = reason
+				o.UpdatedAt = time.Now()
+				return *o, nil
+			}
+		}
+		return *o, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, o.Status, to)
+	}
+	return Order{}, ErrNotFound
+}
+
+// SetGateway records the gateway an order's charge was routed to.
+func SetGateway(id, gateway string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, o := range orders {
+		if o.ID == id {
+			o.Gateway = gateway
+			o.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// SetPayment records an order's authorization and its state. An empty transactionID keeps the
+// recorded one.
+func SetPayment(id, transactionID string, state PaymentState) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, o := range orders {
+		if o.ID == id {
+			if transactionID != "" {
+				o.TransactionID = transactionID
+			}
+			o.PaymentState = state
+			o.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,46 @@
+package orders
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransition_StateMachine(t *testing.T) {
+	o, _ := Create(Order{PlayerID: "p1", PackID: "coins_500", Coins: 500, AmountCents: 499, PaymentKey: "pay-sm-1"})
+	if o.Status != StatusPending {
+		t.Fatalf("new order: want pending, got %s", o.Status)
+	}
+	if got, err := ByPaymentKey("pay-sm-1"); err != nil || got.ID != o.ID {
+		t.Fatalf("ByPaymentKey: %v %+v", err, got)
+	}
+	if _, err := Transition(o.ID, StatusPending, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("pending -> pending: want ErrInvalidTransition, got %v", err)
+	}
+	if o, err := Transition(o.ID, StatusSucceeded, ""); err != nil || o.Status != StatusSucceeded {
+		t.Fatalf("pending -> succeeded: %v", err)
+	}
+	if _, err := Transition(o.ID, StatusFailed, "late failure"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("succeeded -> failed: want ErrInvalidTransition, got %v", err)
+	}
+	if _, err := Transition("ord_missing", StatusFailed, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown order: want ErrNotFound, got %v", err)
+	}
+}
+
+func TestCreate_OneLiveOrderPerPaymentKey(t *testing.T) {
+	first, err := Create(Order{PlayerID: "p1", PackID: "coins_500", Coins: 500, AmountCents: 499, PaymentKey: "pay-dup-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dup, err := Create(Order{PlayerID: "p1", PackID: "coins_500", Coins: 500, AmountCents: 499, PaymentKey: "pay-dup-1"}); !errors.Is(err, ErrDuplicatePaymentKey) || dup.ID != first.ID {
+		t.Fatalf("same key while pending: want ErrDuplicatePaymentKey with %s, got %s %v", first.ID, dup.ID, err)
+	}
+	Transition(first.ID, StatusFailed, "declined")
+	retry, err := Create(Order{PlayerID: "p1", PackID: "coins_500", Coins: 500, AmountCents: 499, PaymentKey: "pay-dup-1"})
+	if err != nil || retry.ID == first.ID {
+		t.Fatalf("a failed order's key may be reused: %+v %v", retry, err)
+	}
+	if got, _ := ByPaymentKey("pay-dup-1"); got.ID != retry.ID {
+		t.Errorf("ByPaymentKey: want the retry %s, got %s", retry.ID, got.ID)
+	}
+}
@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"context"
+	"errors"
+
+	"SnakeGame/breaker"
+)
+
+// BreakerGateway wraps a Gateway with a circuit breaker. While the breaker is open, Charge returns
+// *breaker.OpenError without calling the wrapped gateway. The breaker should be configured with
+// IsOutage so that only provider outages, not rejected charges, open it.
+type BreakerGateway struct {
+	Gateway Gateway
+	Breaker *breaker.Breaker
+}
+
+// Charge implements Gateway.
+func (g *BreakerGateway) Charge(ctx context.Context, amountCents int, idempotencyKey string) error {
+	return g.Breaker.Do(func() error {
+		return g.Gateway.Charge(ctx, amountCents, idempotencyKey)
+	})
+}
+
+// Authorize implements Gateway. Like a charge, it goes through the breaker.
+func (g *BreakerGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	var txn string
+	err := g.Breaker.Do(func() error {
+		var err error
+		txn, err = g.Gateway.Authorize(ctx, amountCents, idempotencyKey)
+		return err
+	})
+	return txn, err
+}
+
+// Capture implements Gateway. It bypasses the breaker: settling a hold that was already placed
+// must not be refused because of failures of new payments.
+func (g *BreakerGateway) Capture(ctx context.Context, transactionID string) error {
+	return g.Gateway.Capture(ctx, transactionID)
+}
+
+// Void implements Gateway. It bypasses the breaker like Capture.
+func (g *BreakerGateway) Void(ctx context.Context, transactionID string) error {
+	return g.Gateway.Void(ctx, transactionID)
+}
+
+// RouteFor implements RouteReporter for a wrapped Router.
+func (g *BreakerGateway) RouteFor(idempotencyKey string) (string, bool) {
+	if rr, ok := g.Gateway.(RouteReporter); ok {
+		return rr.RouteFor(idempotencyKey)
+	}
+	return "", false
+}
+
+// ListCharges implements ChargeLister when the wrapped gateway does. Listing is not a charge, so it
+// bypasses the breaker.
+func (g *BreakerGateway) ListCharges(ctx context.Context) ([]ChargeRecord, error) {
+	if l, ok := g.Gateway.(ChargeLister); ok {
+		return l.ListCharges(ctx)
+	}
+	return nil, ErrListNotSupported
+}
+
+// IsOutage reports whether err means the provider failed to process a charge (timeout, 5xx,
+// network error), as opposed to answering it: declines, rate limits, invalid requests, credential
+// and idempotency errors and pending charges are answers. A call cancelled by the caller is neither.
+func IsOutage(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrPending),
+		errors.Is(err, ErrDeclined),
+		errors.Is(err, ErrRateLimited),
+		errors.Is(err, ErrInvalidRequest),
+		errors.Is(err, ErrUnauthorized),
+		errors.Is(err, ErrIdempotencyConflict),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
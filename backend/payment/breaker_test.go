@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"SnakeGame/breaker"
+)
+
+func TestIsOutage(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{ErrTimeout, true},
+		{&ProviderError{StatusCode: 503, Err: ErrUnavailable}, true},
+		{errors.New("connection refused"), true},
+		{&ProviderError{StatusCode: 402, Code: "card_declined", Err: ErrDeclined}, false},
+		{fmt.Errorf("%w: key reused", ErrIdempotencyConflict), false},
+		{ErrPending, false},
+		{context.Canceled, false},
+	}
+	for _, c := range cases {
+		if got := IsOutage(c.err); got != c.want {
+			t.Errorf("IsOutage(%v): want %v, got %v", c.err, c.want, got)
+		}
+	}
+}
+
+func TestBreakerGateway_DeclinesDoNotOpen(t *testing.T) {
+	b := breaker.New(breaker.Config{FailureThreshold: 1, IsFailure: IsOutage})
+	declining := &recordingGateway{err: ErrDeclined}
+	gw := &BreakerGateway{Gateway: declining, Breaker: b}
+	for i := 0; i < 3; i++ {
+		if err := gw.Charge(context.Background(), 100, ""); !errors.Is(err, ErrDeclined) {
+			t.Fatalf("want ErrDeclined, got %v", err)
+		}
+	}
+	declining.err = ErrTimeout
+	gw.Charge(context.Background(), 100, "")
+	if err := gw.Charge(context.Background(), 100, ""); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("after an outage: want breaker.ErrOpen, got %v", err)
+	}
+	if declining.calls != 4 {
+		t.Errorf("the open breaker must not call the gateway; calls %d", declining.calls)
+	}
+}
+
+// recordingGateway counts calls and returns err.
+type recordingGateway struct {
+	calls int
+	err   error
+}
+
+func (g *recordingGateway) Charge(ctx context.Context, amountCents int, idempotencyKey string) error {
+	g.calls++
+	return g.err
+}
+
+func (g *recordingGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	g.calls++
+	if g.err != nil {
+		return "", g.err
+	}
+	return fmt.Sprintf("auth_%d", g.calls), nil
+}
+
+func (g *recordingGateway) Capture(ctx context.Context, transactionID string) error { return g.err }
+
+func (g *recordingGateway) Void(ctx context.Context, transactionID string) error { return g.err }
@@ -0,0 +1,240 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeCharge is a charge recorded by FakeProvider.
+type FakeCharge struct {
+	ID             string    `json:"id"`
+	IdempotencyKey string    `json:"idempotencyKey"`
+	Amount         int       `json:"amount"`
+	Currency       string    `json:"currency"`
+	Created        time.Time `json:"created"`
+}
+
+// FakeAuthorization is an authorization recorded by FakeProvider.
+type FakeAuthorization struct {
+	ID             string    `json:"id"`
+	IdempotencyKey string    `json:"idempotencyKey"`
+	Amount         int       `json:"amount"`
+	Currency       string    `json:"currency"`
+	State          AuthState `json:"state"`
+}
+
+// FakeFailure is a scripted error response from FakeProvider.
+type FakeFailure struct {
+	Status  int    // HTTP status to return
+	Code    string // error code in the JSON body
+	Message string // error message in the JSON body
+	// RetryAfter is sent as the Retry-After header, in seconds, when positive.
+	RetryAfter int
+}
+
+// FakeProvider is an in-process payment provider speaking the protocol HTTPGateway expects, so the
+// whole purchase flow can run in tests (behind httptest.NewServer) without any network. Charges are
+// deduplicated by idempotency key like a real provider: the same key and amount return the original
+// charge; the same key with another amount is rejected with 409.
+type FakeProvider struct {
+	APIKey string        // when set, requests must carry it as a Bearer token
+	Delay  time.Duration // wait before answering (the request context still applies)
+	Async  bool          // answer new charges with 202 "pending", as a provider that confirms by webhook
+
+	mu         sync.Mutex
+	charges    []FakeCharge
+	byKey      map[string]int // idempotency key -> index in charges
+	auths      []FakeAuthorization
+	authsByKey map[string]int // idempotency key -> index in auths
+	failures   []FakeFailure  // answered in order before any charge or authorization is made
+}
+
+// NewFakeProvider returns an empty fake provider.
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{byKey: map[string]int{}}
+}
+
+// FailNext makes the next len(f) charge or authorization requests fail with the given responses, in order.
+func (p *FakeProvider) FailNext(f ...FakeFailure) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures = append(p.failures, f...)
+}
+
+// Authorizations returns a copy of the authorizations made, oldest first, with their current state.
+func (p *FakeProvider) Authorizations() []FakeAuthorization {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]FakeAuthorization{}, p.auths...)
+}
+
+// Charges returns a copy of the charges made (including captures), oldest first.
+func (p *FakeProvider) Charges() []FakeCharge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]FakeCharge{}, p.charges...)
+}
+
+// ServeHTTP implements POST /v1/charges (create a charge), GET /v1/charges (list charges),
+// POST /v1/authorizations (hold an amount) and POST /v1/authorizations/{id}/capture and /void.
+func (p *FakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	authID, action, isAuth := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/authorizations/"), "/")
+	isAuth = isAuth && strings.HasPrefix(r.URL.Path, "/v1/authorizations/") && (action == "capture" || action == "void")
+	switch {
+	case r.URL.Path == "/v1/charges" && (r.Method == http.MethodPost || r.Method == http.MethodGet):
+	case r.URL.Path == "/v1/authorizations" && r.Method == http.MethodPost:
+	case isAuth && r.Method == http.MethodPost:
+	default:
+		writeFakeError(w, FakeFailure{Status: http.StatusNotFound, Code: "not_found", Message: "unknown endpoint"})
+		return
+	}
+	if p.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(p.Delay):
+		}
+	}
+	if p.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+p.APIKey {
+		writeFakeError(w, FakeFailure{Status: http.StatusUnauthorized, Code: "unauthorized", Message: "invalid API key"})
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": p.Charges()})
+		return
+	}
+	if isAuth {
+		p.settle(w, authID, action)
+		return
+	}
+	var req chargeRequest
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.Amount <= 0 {
+		writeFakeError(w, FakeFailure{Status: http.StatusBadRequest, Code: "invalid_amount", Message: "amount must be a positive integer"})
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.failures) > 0 {
+		f := p.failures[0]
+		p.failures = p.failures[1:]
+		writeFakeError(w, f)
+		return
+	}
+	if r.URL.Path == "/v1/authorizations" {
+		p.authorize(w, req, key)
+		return
+	}
+	if i, ok := p.byKey[key]; ok && key != "" {
+		c := p.charges[i]
+		if c.Amount != req.Amount || c.Currency != req.Currency {
+			writeFakeError(w, FakeFailure{Status: http.StatusConflict, Code: "idempotency_key_reused", Message: "key was used for a different charge"})
+			return
+		}
+		p.writeCharge(w, c)
+		return
+	}
+	c := p.recordCharge(key, req.Amount, req.Currency)
+	if key != "" {
+		p.byKey[key] = len(p.charges) - 1
+	}
+	p.writeCharge(w, c)
+}
+
+// authorize holds an amount, deduplicated by idempotency key like a charge. Callers hold mu.
+func (p *FakeProvider) authorize(w http.ResponseWriter, req chargeRequest, key string) {
+	if i, ok := p.authsByKey[key]; ok && key != "" {
+		a := p.auths[i]
+		if a.Amount != req.Amount || a.Currency != req.Currency {
+			writeFakeError(w, FakeFailure{Status: http.StatusConflict, Code: "idempotency_key_reused", Message: "key was used for a different authorization"})
+			return
+		}
+		p.writeAuthorization(w, a, p.Async)
+		return
+	}
+	a := FakeAuthorization{ID: fmt.Sprintf("auth_%d", len(p.auths)+1), IdempotencyKey: key, Amount: req.Amount, Currency: req.Currency, State: AuthAuthorized}
+	p.auths = append(p.auths, a)
+	if key != "" {
+		if p.authsByKey == nil {
+			p.authsByKey = map[string]int{}
+		}
+		p.authsByKey[key] = len(p.auths) - 1
+	}
+	p.writeAuthorization(w, a, p.Async)
+}
+
+// settle captures or voids an authorization. Repeating the same action succeeds; capturing a voided
+// authorization (or voiding a captured one) is rejected with 409.
+func (p *FakeProvider) settle(w http.ResponseWriter, id, action string) {
+	to := AuthCaptured
+	if action == "void" {
+		to = AuthVoided
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.auths {
+		a := &p.auths[i]
+		if a.ID != id {
+			continue
+		}
+		switch a.State {
+		case to:
+		case AuthAuthorized:
+			a.State = to
+			if to == AuthCaptured {
+				p.recordCharge(a.IdempotencyKey, a.Amount, a.Currency)
+			}
+		default:
+			writeFakeError(w, FakeFailure{Status: http.StatusConflict, Code: "authorization_" + string(a.State), Message: "authorization is " + string(a.State)})
+			return
+		}
+		p.writeAuthorization(w, *a, false)
+		return
+	}
+	writeFakeError(w, FakeFailure{Status: http.StatusNotFound, Code: "no_such_authorization", Message: "unknown authorization"})
+}
+
+// recordCharge appends a charge. Callers hold mu.
+func (p *FakeProvider) recordCharge(key string, amount int, currency string) FakeCharge {
+	c := FakeCharge{ID: fmt.Sprintf("ch_%d", len(p.charges)+1), IdempotencyKey: key, Amount: amount, Currency: currency, Created: time.Now()}
+	p.charges = append(p.charges, c)
+	return c
+}
+
+// writeAuthorization answers with an authorization in its state, or "pending" (202) when pending.
+func (p *FakeProvider) writeAuthorization(w http.ResponseWriter, a FakeAuthorization, pending bool) {
+	status, code := string(a.State), http.StatusOK
+	if pending {
+		status, code = "pending", http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": a.ID, "status": status, "amount": a.Amount, "currency": a.Currency})
+}
+
+// writeCharge answers with a charge: "succeeded", or "pending" (202) when the provider is Async.
+func (p *FakeProvider) writeCharge(w http.ResponseWriter, c FakeCharge) {
+	status, code := "succeeded", http.StatusOK
+	if p.Async {
+		status, code = "pending", http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": c.ID, "status": status, "amount": c.Amount, "currency": c.Currency})
+}
+
+func writeFakeError(w http.ResponseWriter, f FakeFailure) {
+	w.Header().Set("Content-Type", "application/json")
+	if f.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(f.RetryAfter))
+	}
+	w.WriteHeader(f.Status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": f.Code, "message": f.Message}})
+}
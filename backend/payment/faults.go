@@ -0,0 +1,249 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Fault is an outcome FaultGateway can force on a charge or authorization.
+type Fault string
+
+// Faults. Every fault except FaultSuccess and FaultLostResponse fails without reaching the wrapped
+// gateway, so nothing is charged.
+const (
+	FaultSuccess      Fault = "success"            // pass the charge through
+	FaultTimeout      Fault = "timeout"            // ErrTimeout
+	FaultUnavailable  Fault = "unavailable"        // 503 ErrUnavailable
+	FaultDeclined     Fault = "declined"           // 402 card_declined
+	FaultInsufficient Fault = "insufficient_funds" // 402 insufficient_funds
+	FaultFraud        Fault = "fraud"              // 402 fraudulent
+	FaultRateLimited  Fault = "rate_limited"       // 429 with Retry-After: 1
+	FaultInvalid      Fault = "invalid"            // 400 ErrInvalidRequest
+	FaultLostResponse Fault = "lost_response"      // charge through, then report ErrTimeout (the response was lost)
+)
+
+// Latency distributions.
+const (
+	LatencyFixed   = "fixed"   // MinMS
+	LatencyUniform = "uniform" // between MinMS and MaxMS
+	LatencyNormal  = "normal"  // around MeanMS with StdDevMS, never negative
+)
+
+// Latency is the delay added before each charge.
+type Latency struct {
+	Distribution string `json:"distribution"` // "" adds no delay
+	MinMS        int    `json:"minMs,omitempty"`
+	MaxMS        int    `json:"maxMs,omitempty"`
+	MeanMS       int    `json:"meanMs,omitempty"`
+	StdDevMS     int    `json:"stdDevMs,omitempty"`
+}
+
+// FaultConfig describes the faults to inject. For each charge the next Sequence entry is used while
+// any are left; after that ErrorRate percent of charges fail with Error.
+type FaultConfig struct {
+	Sequence  []Fault `json:"sequence,omitempty"`  // scripted outcomes, one per charge, e.g. timeout, timeout, success
+	ErrorRate int     `json:"errorRate,omitempty"` // percent of charges (0-100) failed with Error once Sequence is used up
+	Error     Fault   `json:"error,omitempty"`     // fault for ErrorRate; FaultTimeout when empty
+	Latency   Latency `json:"latency"`
+	Seed      uint64  `json:"seed,omitempty"` // random seed, so a run can be reproduced; random when 0
+}
+
+// FaultStats counts the charges a FaultGateway has seen since it was last configured.
+type FaultStats struct {
+	Calls    int           `json:"calls"`
+	Injected map[Fault]int `json:"injected"` // faults forced, by fault
+}
+
+// FaultGateway is a Gateway decorator that injects errors and latency, to exercise failure
+// handling (retries, the circuit breaker, routing) through the real purchase flow. The zero
+// configuration passes every charge through unchanged. It is safe for concurrent use.
+type FaultGateway struct {
+	Gateway Gateway
+
+	mu    sync.Mutex
+	cfg   FaultConfig
+	rng   *rand.Rand
+	stats FaultStats
+}
+
+// Configure validates cfg and replaces the current configuration, resetting the stats.
+func (g *FaultGateway) Configure(cfg FaultConfig) error {
+	for _, f := range append(append([]Fault{}, cfg.Sequence...), cfg.Error) {
+		if _, ok := faultErrors[f]; !ok && f != FaultSuccess && f != FaultLostResponse && f != "" {
+			return fmt.Errorf("unknown fault %q", f)
+		}
+	}
+	if cfg.ErrorRate < 0 || cfg.ErrorRate > 100 {
+		return fmt.Errorf("errorRate must be between 0 and 100")
+	}
+	l := cfg.Latency
+	switch l.Distribution {
+	case "", LatencyFixed, LatencyNormal:
+	case LatencyUniform:
+		if l.MaxMS < l.MinMS {
+			return fmt.Errorf("latency maxMs must not be below minMs")
+		}
+	default:
+		return fmt.Errorf("unknown latency distribution %q", l.Distribution)
+	}
+	if l.MinMS < 0 || l.MaxMS < 0 || l.MeanMS < 0 || l.StdDevMS < 0 {
+		return fmt.Errorf("latency must not be negative")
+	}
+	if cfg.Error == "" {
+		cfg.Error = FaultTimeout
+	}
+	cfg.Sequence = append([]Fault{}, cfg.Sequence...)
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cfg = cfg
+	g.rng = rand.New(rand.NewPCG(seed, seed))
+	g.stats = FaultStats{Injected: map[Fault]int{}}
+	return nil
+}
+
+// Config returns the current configuration; Sequence holds only the entries not used yet.
+func (g *FaultGateway) Config() FaultConfig {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	cfg := g.cfg
+	cfg.Sequence = append([]Fault{}, cfg.Sequence...)
+	return cfg
+}
+
+// Stats returns the counts since the last Configure.
+func (g *FaultGateway) Stats() FaultStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := FaultStats{Calls: g.stats.Calls, Injected: map[Fault]int{}}
+	for f, n := range g.stats.Injected {
+		s.Injected[f] = n
+	}
+	return s
+}
+
+// Charge implements Gateway.
+func (g *FaultGateway) Charge(ctx context.Context, amountCents int, idempotencyKey string) error {
+	fault, delay := g.next()
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	switch fault {
+	case FaultSuccess:
+		return g.Gateway.Charge(ctx, amountCents, idempotencyKey)
+	case FaultLostResponse:
+		if err := g.Gateway.Charge(ctx, amountCents, idempotencyKey); err != nil {
+			return err
+		}
+		return ErrTimeout
+	}
+	return faultErrors[fault]
+}
+
+// Authorize implements Gateway. Faults apply to authorizations as to charges: FaultLostResponse
+// places the hold, then reports ErrTimeout.
+func (g *FaultGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	fault, delay := g.next()
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	switch fault {
+	case FaultSuccess:
+		return g.Gateway.Authorize(ctx, amountCents, idempotencyKey)
+	case FaultLostResponse:
+		if _, err := g.Gateway.Authorize(ctx, amountCents, idempotencyKey); err != nil {
+			return "", err
+		}
+		return "", ErrTimeout
+	}
+	return "", faultErrors[fault]
+}
+
+// Capture implements Gateway. No faults are injected.
+func (g *FaultGateway) Capture(ctx context.Context, transactionID string) error {
+	return g.Gateway.Capture(ctx, transactionID)
+}
+
+// Void implements Gateway. No faults are injected.
+func (g *FaultGateway) Void(ctx context.Context, transactionID string) error {
+	return g.Gateway.Void(ctx, transactionID)
+}
+
+// ListCharges implements ChargeLister when the wrapped gateway does. No faults are injected.
+func (g *FaultGateway) ListCharges(ctx context.Context) ([]ChargeRecord, error) {
+	if l, ok := g.Gateway.(ChargeLister); ok {
+		return l.ListCharges(ctx)
+	}
+	return nil, ErrListNotSupported
+}
+
+// RouteFor implements RouteReporter for a wrapped Router.
+func (g *FaultGateway) RouteFor(idempotencyKey string) (string, bool) {
+	if rr, ok := g.Gateway.(RouteReporter); ok {
+		return rr.RouteFor(idempotencyKey)
+	}
+	return "", false
+}
+
+// next picks the outcome and delay of the next charge and counts it.
+func (g *FaultGateway) next() (Fault, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.rng == nil { // never configured
+		return FaultSuccess, 0
+	}
+	g.stats.Calls++
+	fault := FaultSuccess
+	switch {
+	case len(g.cfg.Sequence) > 0:
+		fault = g.cfg.Sequence[0]
+		g.cfg.Sequence = g.cfg.Sequence[1:]
+	case g.cfg.ErrorRate > 0 && g.rng.IntN(100) < g.cfg.ErrorRate:
+		fault = g.cfg.Error
+	}
+	if fault != FaultSuccess {
+		g.stats.Injected[fault]++
+	}
+	return fault, g.delay()
+}
+
+// delay draws the latency for one charge. Callers hold mu.
+func (g *FaultGateway) delay() time.Duration {
+	l := g.cfg.Latency
+	ms := 0.0
+	switch l.Distribution {
+	case LatencyFixed:
+		ms = float64(l.MinMS)
+	case LatencyUniform:
+		ms = float64(l.MinMS) + g.rng.Float64()*float64(l.MaxMS-l.MinMS)
+	case LatencyNormal:
+		ms = max(0, float64(l.MeanMS)+g.rng.NormFloat64()*float64(l.StdDevMS))
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// faultErrors are the errors returned by the faults that never reach the wrapped gateway.
+var faultErrors = map[Fault]error{
+	FaultTimeout:      ErrTimeout,
+	FaultUnavailable:  &ProviderError{StatusCode: http.StatusServiceUnavailable, Code: "injected_unavailable", Err: ErrUnavailable},
+	FaultDeclined:     &ProviderError{StatusCode: http.StatusPaymentRequired, Code: "card_declined", Err: ErrDeclined},
+	FaultInsufficient: &ProviderError{StatusCode: http.StatusPaymentRequired, Code: "insufficient_funds", Err: ErrInsufficientFunds},
+	FaultFraud:        &ProviderError{StatusCode: http.StatusPaymentRequired, Code: "fraudulent", Err: ErrFraudSuspected},
+	FaultRateLimited:  &ProviderError{StatusCode: http.StatusTooManyRequests, Code: "rate_limited", Err: ErrRateLimited, RetryAfter: time.Second},
+	FaultInvalid:      &ProviderError{StatusCode: http.StatusBadRequest, Code: "injected_invalid", Err: ErrInvalidRequest},
+}
@@ -0,0 +1,70 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFaultGateway_Sequence(t *testing.T) {
+	stub := &StubGateway{}
+	g := &FaultGateway{Gateway: stub}
+	if err := g.Configure(FaultConfig{Sequence: []Fault{FaultTimeout, FaultUnavailable, FaultLostResponse, FaultSuccess}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	want := []error{ErrTimeout, ErrUnavailable, ErrTimeout, nil, nil}
+	for i, w := range want {
+		if err := g.Charge(ctx, 499, "order-1"); !errors.Is(err, w) || (w == nil && err != nil) {
+			t.Errorf("charge %d: want %v, got %v", i+1, w, err)
+		}
+	}
+	if n := len(stub.Charges()); n != 1 {
+		t.Errorf("the lost response charged once and the retries dedupe; want 1 charge, got %d", n)
+	}
+	s := g.Stats()
+	if s.Calls != 5 || s.Injected[FaultTimeout] != 1 || s.Injected[FaultLostResponse] != 1 || len(g.Config().Sequence) != 0 {
+		t.Errorf("stats: %+v, remaining %v", s, g.Config().Sequence)
+	}
+}
+
+func TestFaultGateway_ErrorRateAndLatency(t *testing.T) {
+	g := &FaultGateway{Gateway: &StubGateway{}}
+	g.Configure(FaultConfig{ErrorRate: 100, Error: FaultDeclined, Latency: Latency{Distribution: LatencyFixed, MinMS: 20}})
+	start := time.Now()
+	if err := g.Charge(context.Background(), 499, ""); !errors.Is(err, ErrDeclined) {
+		t.Errorf("rate 100: want ErrDeclined, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("fixed latency: want at least 20ms, took %s", elapsed)
+	}
+
+	g.Configure(FaultConfig{ErrorRate: 30, Seed: 7})
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if g.Charge(context.Background(), 1, "") != nil {
+			failed++
+		}
+	}
+	if failed < 250 || failed > 350 {
+		t.Errorf("rate 30: want about 300 of 1000 failed, got %d", failed)
+	}
+}
+
+func TestFaultGateway_RejectsBadConfig(t *testing.T) {
+	g := &FaultGateway{Gateway: &StubGateway{}}
+	for _, cfg := range []FaultConfig{
+		{Sequence: []Fault{"explode"}},
+		{ErrorRate: 101},
+		{Latency: Latency{Distribution: "poisson"}},
+		{Latency: Latency{Distribution: LatencyUniform, MinMS: 50, MaxMS: 10}},
+	} {
+		if g.Configure(cfg) == nil {
+			t.Errorf("want an error for %+v", cfg)
+		}
+	}
+	if err := g.Charge(context.Background(), 1, ""); err != nil {
+		t.Errorf("an unconfigured gateway passes charges through, got %v", err)
+	}
+}
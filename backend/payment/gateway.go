@@ -3,23 +3,84 @@ package payment
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 )
 
 // ErrTimeout is returned when the payment gateway times out.
 var ErrTimeout = errors.New("payment gateway timeout")
 
-// Gateway performs the actual charge. In production this would call an external API.
+// ErrAuthorizationState is returned when an authorization cannot be captured or voided in its
+// current state, e.g. capturing a voided authorization.
+var ErrAuthorizationState = errors.New("authorization cannot be changed in its current state")
+
+// Gateway performs the actual payment. In production this would call an external API.
+// Charge takes the money in one step. Authorize only holds the amount and returns a transaction id;
+// Capture then takes the held money and Void releases it, so a purchase that cannot be delivered
+// is never paid for. Capturing a captured or voiding a voided authorization succeeds again.
 type Gateway interface {
 	Charge(ctx context.Context, amountCents int, idempotencyKey string) error
+	Authorize(ctx context.Context, amountCents int, idempotencyKey string) (transactionID string, err error)
+	Capture(ctx context.Context, transactionID string) error
+	Void(ctx context.Context, transactionID string) error
 }
 
-// StubGateway is an in-memory stub. When SimulateTimeout is true, Charge returns ErrTimeout
-// (to test retry logic). Otherwise it succeeds immediately.
+// AuthState is the state of an authorization.
+type AuthState string
+
+// Authorization states. Only an authorized transaction can change, once.
+const (
+	AuthAuthorized AuthState = "authorized" // amount held, not taken
+	AuthCaptured   AuthState = "captured"   // money taken (a charge was made)
+	AuthVoided     AuthState = "voided"     // hold released, nothing taken
+)
+
+// AuthorizationRecord is an authorization made by StubGateway.
+type AuthorizationRecord struct {
+	TransactionID  string    `json:"transactionId"`
+	IdempotencyKey string    `json:"idempotencyKey"`
+	AmountCents    int       `json:"amountCents"`
+	State          AuthState `json:"state"`
+	At             time.Time `json:"at"`
+}
+
+// ErrListNotSupported is returned when a gateway cannot list its charges.
+var ErrListNotSupported = errors.New("payment gateway cannot list charges")
+
+// ChargeLister is implemented by gateways that can list the charges the provider has made, so they
+// can be reconciled against orders.
+type ChargeLister interface {
+	ListCharges(ctx context.Context) ([]ChargeRecord, error)
+}
+
+// ChargeRecord is a charge made by a gateway.
+type ChargeRecord struct {
+	ID             string    `json:"id,omitempty"` // provider charge id
+	IdempotencyKey string    `json:"idempotencyKey"`
+	AmountCents    int       `json:"amountCents"`
+	Gateway        string    `json:"gateway,omitempty"` // route name, when listed through a Router
+	At             time.Time `json:"at"`
+}
+
+// StubGateway is an in-memory stub. When SimulateTimeout is true, Charge and Authorize return
+// ErrTimeout (to test retry logic). Otherwise they succeed immediately and record the charge or
+// authorization; a capture records a charge too, so Charges lists all money taken.
+// Like a real provider it dedupes by idempotency key: a repeated Charge (or Authorize) with the same
+// key and amount returns the original result without charging again, and the same key with a
+// different amount returns ErrIdempotencyConflict. An empty key is never deduped.
 type StubGateway struct {
 	SimulateTimeout bool
 	// SimulateDelay optionally sleeps before returning (e.g. to simulate slow response).
 	SimulateDelay time.Duration
+	// SimulatePending records the charge but returns ErrPending, as a provider that confirms by webhook.
+	SimulatePending bool
+
+	mu         sync.Mutex
+	charges    []ChargeRecord
+	byKey      map[string]int // idempotency key -> index in charges
+	auths      []AuthorizationRecord
+	authsByKey map[string]int // idempotency key -> index in auths
 }
 
 // Charge implements Gateway. When SimulateTimeout is true, returns ErrTimeout.
@@ -34,5 +95,123 @@ func (g *StubGateway) Charge(ctx context.Context, amountCents int, idempotencyKe
 	if g.SimulateTimeout {
 		return ErrTimeout
 	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if i, ok := g.byKey[idempotencyKey]; ok && idempotencyKey != "" {
+		if c := g.charges[i]; c.AmountCents != amountCents {
+			return fmt.Errorf("%w: key %q was charged %d cents, not %d", ErrIdempotencyConflict, idempotencyKey, c.AmountCents, amountCents)
+		}
+		return g.result() // already charged: same result, no second charge
+	}
+	g.recordCharge(idempotencyKey, amountCents)
+	if idempotencyKey != "" {
+		if g.byKey == nil {
+			g.byKey = map[string]int{}
+		}
+		g.byKey[idempotencyKey] = len(g.charges) - 1
+	}
+	return g.result()
+}
+
+// result is the outcome reported for a recorded charge.
+func (g *StubGateway) result() error {
+	if g.SimulatePending {
+		return ErrPending
+	}
 	return nil
 }
+
+// Charges returns a copy of the charges made, oldest first.
+func (g *StubGateway) Charges() []ChargeRecord {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]ChargeRecord{}, g.charges...)
+}
+
+// ListCharges implements ChargeLister.
+func (g *StubGateway) ListCharges(ctx context.Context) ([]ChargeRecord, error) {
+	return g.Charges(), nil
+}
+
+// Authorize implements Gateway. When SimulateTimeout is true, returns ErrTimeout. With
+// SimulatePending the authorization is recorded and returned with ErrPending.
+func (g *StubGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	if g.SimulateDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(g.SimulateDelay):
+		}
+	}
+	if g.SimulateTimeout {
+		return "", ErrTimeout
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if i, ok := g.authsByKey[idempotencyKey]; ok && idempotencyKey != "" {
+		a := g.auths[i]
+		if a.AmountCents != amountCents {
+			return "", fmt.Errorf("%w: key %q was authorized for %d cents, not %d", ErrIdempotencyConflict, idempotencyKey, a.AmountCents, amountCents)
+		}
+		return a.TransactionID, g.result() // already authorized: same transaction
+	}
+	a := AuthorizationRecord{
+		TransactionID: fmt.Sprintf("auth_%d", len(g.auths)+1), IdempotencyKey: idempotencyKey, AmountCents: amountCents, State: AuthAuthorized, At: time.Now(),
+	}
+	g.auths = append(g.auths, a)
+	if idempotencyKey != "" {
+		if g.authsByKey == nil {
+			g.authsByKey = map[string]int{}
+		}
+		g.authsByKey[idempotencyKey] = len(g.auths) - 1
+	}
+	return a.TransactionID, g.result()
+}
+
+// Capture implements Gateway: the authorized amount is charged.
+func (g *StubGateway) Capture(ctx context.Context, transactionID string) error {
+	return g.settle(transactionID, AuthCaptured)
+}
+
+// Void implements Gateway: the authorization is released without charging.
+func (g *StubGateway) Void(ctx context.Context, transactionID string) error {
+	return g.settle(transactionID, AuthVoided)
+}
+
+// Authorizations returns a copy of the authorizations made, oldest first, with their current state.
+func (g *StubGateway) Authorizations() []AuthorizationRecord {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]AuthorizationRecord{}, g.auths...)
+}
+
+// settle moves an authorized transaction to captured or voided. Repeating the same move succeeds.
+func (g *StubGateway) settle(transactionID string, to AuthState) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := range g.auths {
+		a := &g.auths[i]
+		if a.TransactionID != transactionID {
+			continue
+		}
+		switch a.State {
+		case to:
+			return nil
+		case AuthAuthorized:
+			a.State = to
+			if to == AuthCaptured {
+				g.recordCharge(a.IdempotencyKey, a.AmountCents)
+			}
+			return nil
+		}
+		return fmt.Errorf("%w: %s is %s", ErrAuthorizationState, transactionID, a.State)
+	}
+	return fmt.Errorf("%w: unknown transaction %q", ErrInvalidRequest, transactionID)
+}
+
+// recordCharge appends a charge. Callers hold mu.
+func (g *StubGateway) recordCharge(idempotencyKey string, amountCents int) {
+	g.charges = append(g.charges, ChargeRecord{
+		ID: fmt.Sprintf("ch_%d", len(g.charges)+1), IdempotencyKey: idempotencyKey, AmountCents: amountCents, At: time.Now(),
+	})
+}
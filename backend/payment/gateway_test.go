@@ -2,6 +2,7 @@ package payment
 
 import (
 	"context"
+	"errors"
 	"testing"
 )
 
@@ -22,3 +23,64 @@ func TestStubGateway_SimulateTimeout(t *testing.T) {
 		t.Fatalf("expected ErrTimeout when SimulateTimeout=true, got %v", err)
 	}
 }
+
+func TestStubGateway_DedupesByKey(t *testing.T) {
+	g := &StubGateway{}
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := g.Charge(ctx, 499, "order-1"); err != nil {
+			t.Fatalf("charge %d: %v", i, err)
+		}
+	}
+	if err := g.Charge(ctx, 999, "order-1"); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Errorf("same key, different amount: want ErrIdempotencyConflict, got %v", err)
+	}
+	g.Charge(ctx, 100, "")
+	g.Charge(ctx, 100, "")
+	charges := g.Charges()
+	if len(charges) != 3 || charges[0].IdempotencyKey != "order-1" || charges[0].AmountCents != 499 {
+		t.Errorf("want one keyed charge and two unkeyed, got %+v", charges)
+	}
+}
+
+func TestStubGateway_AuthorizeCaptureVoid(t *testing.T) {
+	g := &StubGateway{}
+	ctx := context.Background()
+	held, err := g.Authorize(ctx, 499, "order-1")
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if again, _ := g.Authorize(ctx, 499, "order-1"); again != held {
+		t.Errorf("same key: want transaction %s, got %s", held, again)
+	}
+	if len(g.Charges()) != 0 {
+		t.Fatalf("an authorization must not charge: %+v", g.Charges())
+	}
+	if err := g.Capture(ctx, held); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if err := g.Capture(ctx, held); err != nil {
+		t.Errorf("repeated capture: %v", err)
+	}
+	if err := g.Void(ctx, held); !errors.Is(err, ErrAuthorizationState) {
+		t.Errorf("void after capture: want ErrAuthorizationState, got %v", err)
+	}
+	if c := g.Charges(); len(c) != 1 || c[0].AmountCents != 499 || c[0].IdempotencyKey != "order-1" {
+		t.Errorf("capture should charge once: %+v", c)
+	}
+
+	voided, _ := g.Authorize(ctx, 100, "order-2")
+	if err := g.Void(ctx, voided); err != nil {
+		t.Fatalf("void: %v", err)
+	}
+	if err := g.Capture(ctx, voided); !errors.Is(err, ErrAuthorizationState) {
+		t.Errorf("capture after void: want ErrAuthorizationState, got %v", err)
+	}
+	if err := g.Capture(ctx, "auth_missing"); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("unknown transaction: want ErrInvalidRequest, got %v", err)
+	}
+	auths := g.Authorizations()
+	if len(auths) != 2 || auths[0].State != AuthCaptured || auths[1].State != AuthVoided {
+		t.Errorf("authorizations: %+v", auths)
+	}
+}
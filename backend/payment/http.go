@@ -0,0 +1,254 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by gateways for provider responses. ProviderError wraps one of them, so callers
+// can test with errors.Is and still read the provider's status and code. ErrInsufficientFunds and
+// ErrFraudSuspected are kinds of decline and also match ErrDeclined.
+var (
+	ErrDeclined            = errors.New("payment declined")
+	ErrInsufficientFunds   = fmt.Errorf("%w: insufficient funds", ErrDeclined)
+	ErrFraudSuspected      = fmt.Errorf("%w: suspected fraud", ErrDeclined)
+	ErrRateLimited         = errors.New("payment provider rate limit exceeded")
+	ErrInvalidRequest      = errors.New("invalid payment request")
+	ErrUnauthorized        = errors.New("payment provider rejected credentials")
+	ErrIdempotencyConflict = errors.New("idempotency key reused with different parameters")
+	ErrUnavailable         = errors.New("payment provider unavailable") // provider outage
+)
+
+// ProviderError is an error response from a payment provider.
+type ProviderError struct {
+	StatusCode int    // HTTP status of the response
+	Code       string // provider error code, e.g. "card_declined" ("" when the body had none)
+	Message    string // provider message
+	Err        error  // one of the sentinel errors above (or ErrTimeout)
+	// RetryAfter is the provider's Retry-After hint (rate limits, outages); 0 when it sent none.
+	RetryAfter time.Duration
+}
+
+func (e *ProviderError) Error() string {
+	msg := e.Err.Error()
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// RetryDelay returns the provider's Retry-After hint, so retry.Do waits at least that long.
+func (e *ProviderError) RetryDelay() time.Duration { return e.RetryAfter }
+
+// HTTPGateway charges through a REST payment provider: POST {BaseURL}/v1/charges with a JSON body
+// {"amount": cents, "currency": "USD"} and the idempotency key in the Idempotency-Key header.
+// Authorizations use /v1/authorizations the same way, then /capture or /void on the authorization.
+// Error responses ({"error": {"code": "...", "message": "..."}}) are returned as *ProviderError.
+// A 202 or a "pending" status returns ErrPending: the outcome arrives later as a webhook event.
+// The request honors ctx, so a deadline or cancellation stops the call.
+type HTTPGateway struct {
+	BaseURL  string       // provider base URL, without a trailing slash
+	APIKey   string       // sent as a Bearer token when set
+	Currency string       // ISO currency code; "USD" when empty
+	Client   *http.Client // http.DefaultClient when nil
+}
+
+// chargeRequest is the provider's charge request body.
+type chargeRequest struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// chargeResponse is the provider's charge response body (success or error).
+type chargeResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Charge implements Gateway.
+func (g *HTTPGateway) Charge(ctx context.Context, amountCents int, idempotencyKey string) error {
+	_, err := g.post(ctx, "/v1/charges", g.amountBody(amountCents), idempotencyKey)
+	return err
+}
+
+// Authorize implements Gateway with POST {BaseURL}/v1/authorizations (same body as a charge). The
+// provider answers with the authorization id, which is the transaction id.
+func (g *HTTPGateway) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	body, err := g.post(ctx, "/v1/authorizations", g.amountBody(amountCents), idempotencyKey)
+	return body.ID, err
+}
+
+// Capture implements Gateway with POST {BaseURL}/v1/authorizations/{id}/capture.
+func (g *HTTPGateway) Capture(ctx context.Context, transactionID string) error {
+	_, err := g.post(ctx, "/v1/authorizations/"+url.PathEscape(transactionID)+"/capture", nil, "")
+	return err
+}
+
+// Void implements Gateway with POST {BaseURL}/v1/authorizations/{id}/void.
+func (g *HTTPGateway) Void(ctx context.Context, transactionID string) error {
+	_, err := g.post(ctx, "/v1/authorizations/"+url.PathEscape(transactionID)+"/void", nil, "")
+	return err
+}
+
+// amountBody encodes a charge or authorization request.
+func (g *HTTPGateway) amountBody(amountCents int) []byte {
+	currency := g.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+	payload, _ := json.Marshal(chargeRequest{Amount: amountCents, Currency: currency})
+	return payload
+}
+
+// post sends a request to the provider and decodes the response. A "failed" status is a decline; a
+// 202 or "pending" status returns the body with ErrPending.
+func (g *HTTPGateway) post(ctx context.Context, path string, payload []byte, idempotencyKey string) (chargeResponse, error) {
+	var body chargeResponse
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(g.BaseURL, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return body, &ProviderError{Err: ErrInvalidRequest, Message: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	if g.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.APIKey)
+	}
+
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return body, ctx.Err() // our deadline or cancellation, not the provider's fault
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return body, ErrTimeout
+		}
+		return body, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	decodeErr := json.Unmarshal(raw, &body)
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		if decodeErr == nil && body.Status == "failed" {
+			return body, &ProviderError{StatusCode: res.StatusCode, Err: ErrDeclined, Message: "charge " + body.ID + " failed"}
+		}
+		if res.StatusCode == http.StatusAccepted || (decodeErr == nil && body.Status == "pending") {
+			return body, ErrPending // the provider will send a webhook with the outcome
+		}
+		return body, nil
+	}
+	perr := &ProviderError{StatusCode: res.StatusCode}
+	if decodeErr == nil && body.Error != nil {
+		perr.Code, perr.Message = body.Error.Code, body.Error.Message
+	}
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		perr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	perr.Err = classify(res.StatusCode, perr.Code)
+	return body, perr
+}
+
+// chargeList is the provider's list-charges response body.
+type chargeList struct {
+	Data []struct {
+		ID             string    `json:"id"`
+		IdempotencyKey string    `json:"idempotencyKey"`
+		Amount         int       `json:"amount"`
+		Created        time.Time `json:"created"`
+	} `json:"data"`
+}
+
+// ListCharges implements ChargeLister with GET {BaseURL}/v1/charges.
+func (g *HTTPGateway) ListCharges(ctx context.Context) ([]ChargeRecord, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(g.BaseURL, "/")+"/v1/charges", nil)
+	if err != nil {
+		return nil, &ProviderError{Err: ErrInvalidRequest, Message: err.Error()}
+	}
+	if g.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.APIKey)
+	}
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &ProviderError{StatusCode: res.StatusCode, Err: classify(res.StatusCode, "")}
+	}
+	var body chargeList
+	if err := json.NewDecoder(io.LimitReader(res.Body, 16<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: bad charge list: %v", ErrUnavailable, err)
+	}
+	out := make([]ChargeRecord, 0, len(body.Data))
+	for _, c := range body.Data {
+		out = append(out, ChargeRecord{ID: c.ID, IdempotencyKey: c.IdempotencyKey, AmountCents: c.Amount, At: c.Created})
+	}
+	return out, nil
+}
+
+// classify maps a provider error response to a sentinel error: the error code wins when it is
+// known, otherwise the HTTP status decides.
+func classify(status int, code string) error {
+	switch code {
+	case "card_declined", "do_not_honor", "expired_card":
+		return ErrDeclined
+	case "insufficient_funds":
+		return ErrInsufficientFunds
+	case "fraudulent", "suspected_fraud":
+		return ErrFraudSuspected
+	case "rate_limited":
+		return ErrRateLimited
+	case "idempotency_key_reused":
+		return ErrIdempotencyConflict
+	case "authorization_captured", "authorization_voided":
+		return ErrAuthorizationState
+	}
+	switch {
+	case status == http.StatusPaymentRequired:
+		return ErrDeclined
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUnauthorized
+	case status == http.StatusConflict:
+		return ErrIdempotencyConflict
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status >= 500:
+		return ErrUnavailable
+	}
+	return ErrInvalidRequest
+}
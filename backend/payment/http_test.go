@@ -0,0 +1,136 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFakeGateway(t *testing.T) (*HTTPGateway, *FakeProvider) {
+	t.Helper()
+	fake := NewFakeProvider()
+	fake.APIKey = "sk_test"
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return &HTTPGateway{BaseURL: srv.URL, APIKey: "sk_test"}, fake
+}
+
+func TestHTTPGateway_ChargeSendsKeyAndDedupes(t *testing.T) {
+	gw, fake := newFakeGateway(t)
+	ctx := context.Background()
+	if err := gw.Charge(ctx, 499, "order-1"); err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+	if err := gw.Charge(ctx, 499, "order-1"); err != nil {
+		t.Fatalf("repeat with same key: %v", err)
+	}
+	charges := fake.Charges()
+	if len(charges) != 1 || charges[0].Amount != 499 || charges[0].IdempotencyKey != "order-1" || charges[0].Currency != "USD" {
+		t.Fatalf("provider charges: %+v", charges)
+	}
+	err := gw.Charge(ctx, 999, "order-1")
+	var perr *ProviderError
+	if !errors.Is(err, ErrIdempotencyConflict) || !errors.As(err, &perr) || perr.StatusCode != http.StatusConflict {
+		t.Errorf("same key, other amount: want ErrIdempotencyConflict with 409, got %v", err)
+	}
+}
+
+func TestHTTPGateway_MapsErrors(t *testing.T) {
+	gw, fake := newFakeGateway(t)
+	cases := []struct {
+		failure FakeFailure
+		want    error
+	}{
+		{FakeFailure{Status: 402, Code: "card_declined", Message: "Your card was declined."}, ErrDeclined},
+		{FakeFailure{Status: 400, Code: "invalid_amount"}, ErrInvalidRequest},
+		{FakeFailure{Status: 401}, ErrUnauthorized},
+		{FakeFailure{Status: 504}, ErrTimeout},
+		{FakeFailure{Status: 402, Code: "insufficient_funds"}, ErrInsufficientFunds},
+		{FakeFailure{Status: 402, Code: "fraudulent"}, ErrFraudSuspected},
+		{FakeFailure{Status: 429, Code: "rate_limited"}, ErrRateLimited},
+		{FakeFailure{Status: 500}, ErrUnavailable},
+	}
+	for _, tc := range cases {
+		fake.FailNext(tc.failure)
+		err := gw.Charge(context.Background(), 100, "k")
+		var perr *ProviderError
+		if !errors.Is(err, tc.want) || !errors.As(err, &perr) || perr.Code != tc.failure.Code {
+			t.Errorf("status %d code %q: want %v, got %v", tc.failure.Status, tc.failure.Code, tc.want, err)
+		}
+	}
+	if n := len(fake.Charges()); n != 0 {
+		t.Errorf("failed requests must not charge; got %d charges", n)
+	}
+}
+
+func TestHTTPGateway_HonorsDeadline(t *testing.T) {
+	gw, fake := newFakeGateway(t)
+	fake.Delay = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := gw.Charge(ctx, 100, "slow")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("charge did not stop at the deadline (took %s)", time.Since(start))
+	}
+}
+
+func TestHTTPGateway_ListCharges(t *testing.T) {
+	gw, _ := newFakeGateway(t)
+	ctx := context.Background()
+	gw.Charge(ctx, 499, "order-1")
+	gw.Charge(ctx, 999, "order-2")
+	router := &Router{Primary: Route{"primary", gw}, Secondary: Route{"secondary", &StubGateway{}}}
+	charges, err := router.ListCharges(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(charges) != 2 || charges[1].IdempotencyKey != "order-2" || charges[1].AmountCents != 999 || charges[1].ID == "" || charges[1].Gateway != "primary" {
+		t.Errorf("charges: %+v", charges)
+	}
+}
+
+func TestHTTPGateway_AuthorizeCaptureVoid(t *testing.T) {
+	gw, fake := newFakeGateway(t)
+	ctx := context.Background()
+	held, err := gw.Authorize(ctx, 499, "order-1")
+	if err != nil || held == "" {
+		t.Fatalf("authorize: %q, %v", held, err)
+	}
+	if len(fake.Charges()) != 0 {
+		t.Fatalf("an authorization must not charge: %+v", fake.Charges())
+	}
+	if err := gw.Capture(ctx, held); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if err := gw.Void(ctx, held); !errors.Is(err, ErrAuthorizationState) {
+		t.Errorf("void after capture: want ErrAuthorizationState, got %v", err)
+	}
+	if c := fake.Charges(); len(c) != 1 || c[0].Amount != 499 || c[0].IdempotencyKey != "order-1" {
+		t.Errorf("capture should charge once: %+v", c)
+	}
+
+	voided, _ := gw.Authorize(ctx, 100, "order-2")
+	if err := gw.Void(ctx, voided); err != nil {
+		t.Fatalf("void: %v", err)
+	}
+	if err := gw.Capture(ctx, voided); !errors.Is(err, ErrAuthorizationState) {
+		t.Errorf("capture after void: want ErrAuthorizationState, got %v", err)
+	}
+	if err := gw.Capture(ctx, "auth_missing"); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("unknown authorization: want ErrInvalidRequest, got %v", err)
+	}
+	fake.FailNext(FakeFailure{Status: 402, Code: "insufficient_funds"})
+	if _, err := gw.Authorize(ctx, 100, "order-3"); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("declined authorization: want ErrInsufficientFunds, got %v", err)
+	}
+	if a := fake.Authorizations(); len(a) != 2 || a[0].State != AuthCaptured || a[1].State != AuthVoided {
+		t.Errorf("provider authorizations: %+v", a)
+	}
+}
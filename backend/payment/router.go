@@ -0,0 +1,224 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"SnakeGame/breaker"
+)
+
+// Policy decides which of a Router's gateways gets a charge.
+type Policy string
+
+// Routing policies.
+const (
+	PolicyFailover Policy = "failover" // primary; the secondary only when the primary refused the charge without making it
+	PolicyWeighted Policy = "weighted" // PrimaryWeight percent of charges to the primary, the rest to the secondary
+	PolicyAmount   Policy = "amount"   // charges below AmountThresholdCents to the primary, the rest to the secondary
+)
+
+// Route is a gateway with the name recorded on the orders it charges.
+type Route struct {
+	Name    string
+	Gateway Gateway
+}
+
+// RouteReporter is implemented by gateways that send charges to one of several providers.
+type RouteReporter interface {
+	// RouteFor returns the name of the gateway charges with idempotencyKey are sent to.
+	RouteFor(idempotencyKey string) (name string, ok bool)
+}
+
+// Router sends each charge or authorization to its Primary or Secondary gateway according to Policy.
+//
+// Both gateways see the purchase's own idempotency key, and once a key has been sent to a gateway
+// every later attempt with that key (retries) goes to the same gateway. That keeps retries
+// deduplicated by the provider that may already have charged, and means one purchase can never be
+// charged by both. PolicyFailover only moves a key to the secondary on its first attempt, and only
+// when the primary certainly did not charge: it was not reached (connection refused, open breaker)
+// or it refused with 429 or 503. A timeout is ambiguous, so the key stays on the primary.
+// Attempts with the same key are expected to be sequential, as retries are.
+type Router struct {
+	Primary              Route
+	Secondary            Route
+	Policy               Policy
+	PrimaryWeight        int             // PolicyWeighted: percent of charges sent to the primary (0-100)
+	AmountThresholdCents int             // PolicyAmount: charges of at least this amount go to the secondary
+	Rand                 func(n int) int // random source in [0, n) for PolicyWeighted; rand.IntN when nil
+
+	mu     sync.Mutex
+	pinned map[string]int // idempotency key -> route index (0 primary, 1 secondary)
+}
+
+// Charge implements Gateway.
+func (r *Router) Charge(ctx context.Context, amountCents int, idempotencyKey string) error {
+	_, err := r.send(amountCents, idempotencyKey, func(g Gateway) error {
+		return g.Charge(ctx, amountCents, idempotencyKey)
+	})
+	return err
+}
+
+// Authorize implements Gateway. Authorizations are routed like charges. The returned transaction
+// id is the provider's prefixed with the route name ("primary:auth_1"), so a capture or void goes to
+// the gateway that placed the hold and ids issued by the two providers cannot collide.
+func (r *Router) Authorize(ctx context.Context, amountCents int, idempotencyKey string) (string, error) {
+	var txn string
+	idx, err := r.send(amountCents, idempotencyKey, func(g Gateway) error {
+		var err error
+		txn, err = g.Authorize(ctx, amountCents, idempotencyKey)
+		return err
+	})
+	if txn != "" {
+		txn = r.route(idx).Name + ":" + txn
+	}
+	return txn, err
+}
+
+// Capture implements Gateway. A transaction id this Router did not issue returns ErrInvalidRequest.
+func (r *Router) Capture(ctx context.Context, transactionID string) error {
+	g, txn, err := r.holder(transactionID)
+	if err != nil {
+		return err
+	}
+	return g.Capture(ctx, txn)
+}
+
+// Void implements Gateway. A transaction id this Router did not issue returns ErrInvalidRequest.
+func (r *Router) Void(ctx context.Context, transactionID string) error {
+	g, txn, err := r.holder(transactionID)
+	if err != nil {
+		return err
+	}
+	return g.Void(ctx, txn)
+}
+
+// send makes one call (a charge or an authorization) on the route pinned for the key, failing over
+// as described on Router, and returns the index of the route that answered.
+func (r *Router) send(amountCents int, idempotencyKey string, call func(Gateway) error) (int, error) {
+	idx, pinned := r.pin(idempotencyKey, amountCents)
+	err := call(r.route(idx).Gateway)
+	if !pinned && idx == 0 && r.Policy == PolicyFailover && notCharged(err) {
+		r.repin(idempotencyKey, 1)
+		idx = 1
+		err = call(r.Secondary.Gateway)
+	}
+	return idx, err
+}
+
+// holder splits a transaction id issued by Authorize into the gateway that placed the hold and the
+// provider's own id.
+func (r *Router) holder(transactionID string) (Gateway, string, error) {
+	name, txn, ok := strings.Cut(transactionID, ":")
+	for _, route := range []Route{r.Primary, r.Secondary} {
+		if ok && route.Name == name {
+			return route.Gateway, txn, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: unknown authorization %q", ErrInvalidRequest, transactionID)
+}
+
+// RouteFor implements RouteReporter.
+func (r *Router) RouteFor(idempotencyKey string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	idx, ok := r.pinned[idempotencyKey]
+	if !ok {
+		return "", false
+	}
+	return r.route(idx).Name, true
+}
+
+// ListCharges implements ChargeLister: the charges of both gateways, each tagged with its route name.
+func (r *Router) ListCharges(ctx context.Context) ([]ChargeRecord, error) {
+	var out []ChargeRecord
+	for _, route := range []Route{r.Primary, r.Secondary} {
+		l, ok := route.Gateway.(ChargeLister)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrListNotSupported, route.Name)
+		}
+		charges, err := l.ListCharges(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", route.Name, err)
+		}
+		for _, c := range charges {
+			c.Gateway = route.Name
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// pin returns the route for a key and whether it was already pinned by an earlier attempt; a new
+// key is routed by the policy and pinned before the charge is sent. Keys are never pinned when empty.
+func (r *Router) pin(key string, amountCents int) (idx int, pinned bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if idx, ok := r.pinned[key]; ok {
+		return idx, true
+	}
+	idx = r.choose(amountCents)
+	if key != "" {
+		if r.pinned == nil {
+			r.pinned = map[string]int{}
+		}
+		r.pinned[key] = idx
+	}
+	return idx, false
+}
+
+// repin moves a key to another route (failover).
+func (r *Router) repin(key string, idx int) {
+	if key == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pinned[key] = idx
+}
+
+// choose applies the policy to a new charge. Callers hold mu.
+func (r *Router) choose(amountCents int) int {
+	switch r.Policy {
+	case PolicyWeighted:
+		n := rand.IntN
+		if r.Rand != nil {
+			n = r.Rand
+		}
+		if n(100) >= r.PrimaryWeight {
+			return 1
+		}
+	case PolicyAmount:
+		if amountCents >= r.AmountThresholdCents {
+			return 1
+		}
+	}
+	return 0
+}
+
+// route returns the route at idx.
+func (r *Router) route(idx int) Route {
+	if idx == 1 {
+		return r.Secondary
+	}
+	return r.Primary
+}
+
+// notCharged reports whether err guarantees the gateway did not charge: the request never reached
+// the provider, or the provider refused it outright as overloaded or unavailable.
+func notCharged(err error) bool {
+	if errors.Is(err, breaker.ErrOpen) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var perr *ProviderError
+	return errors.As(err, &perr) && (perr.StatusCode == http.StatusTooManyRequests || perr.StatusCode == http.StatusServiceUnavailable)
+}
@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+var unavailable = &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: ErrUnavailable}
+
+func TestRouter_FailsOverWhenPrimaryDidNotCharge(t *testing.T) {
+	primary := &recordingGateway{err: unavailable}
+	secondary := &StubGateway{}
+	r := &Router{Primary: Route{"primary", primary}, Secondary: Route{"secondary", secondary}, Policy: PolicyFailover}
+
+	if err := r.Charge(context.Background(), 499, "order-1"); err != nil {
+		t.Fatalf("failover charge: %v", err)
+	}
+	if name, _ := r.RouteFor("order-1"); name != "secondary" {
+		t.Errorf("route: want secondary, got %q", name)
+	}
+	// A retry of the same purchase stays on the secondary, which dedupes it by key.
+	primary.err = nil
+	r.Charge(context.Background(), 499, "order-1")
+	if primary.calls != 1 || len(secondary.Charges()) != 1 || secondary.Charges()[0].IdempotencyKey != "order-1" {
+		t.Errorf("want one primary attempt and one secondary charge with the same key; primary %d, secondary %+v", primary.calls, secondary.Charges())
+	}
+}
+
+func TestRouter_NeverFailsOverAfterAmbiguousAttempt(t *testing.T) {
+	primary := &recordingGateway{err: ErrTimeout} // the primary may have charged
+	secondary := &recordingGateway{}
+	r := &Router{Primary: Route{"primary", primary}, Secondary: Route{"secondary", secondary}, Policy: PolicyFailover}
+
+	r.Charge(context.Background(), 499, "order-2")
+	primary.err = unavailable
+	if err := r.Charge(context.Background(), 499, "order-2"); err != unavailable {
+		t.Errorf("retry: want the primary's error, got %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Error("a key that may be charged by the primary must never reach the secondary")
+	}
+	if name, _ := r.RouteFor("order-2"); name != "primary" {
+		t.Errorf("route: want primary, got %q", name)
+	}
+
+	// A refused connection means the primary was never reached.
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	ln.Close()
+	r.Primary.Gateway = &HTTPGateway{BaseURL: "http://" + ln.Addr().String()}
+	if err := r.Charge(context.Background(), 499, "order-3"); err != nil || secondary.calls != 1 {
+		t.Errorf("connection refused: want failover, err %v, secondary calls %d", err, secondary.calls)
+	}
+}
+
+func TestRouter_WeightedAndAmountPolicies(t *testing.T) {
+	primary, secondary := &recordingGateway{}, &recordingGateway{}
+	roll := 0
+	r := &Router{
+		Primary: Route{"primary", primary}, Secondary: Route{"secondary", secondary},
+		Policy: PolicyWeighted, PrimaryWeight: 70, Rand: func(n int) int { return roll },
+	}
+	for _, roll = range []int{0, 69, 70, 99} {
+		r.Charge(context.Background(), 100, "")
+	}
+	if primary.calls != 2 || secondary.calls != 2 {
+		t.Errorf("weighted 70: want 2/2 for rolls 0,69,70,99; got %d/%d", primary.calls, secondary.calls)
+	}
+
+	r.Policy, r.AmountThresholdCents = PolicyAmount, 1000
+	r.Charge(context.Background(), 999, "small")
+	r.Charge(context.Background(), 1999, "large")
+	small, _ := r.RouteFor("small")
+	large, _ := r.RouteFor("large")
+	if small != "primary" || large != "secondary" {
+		t.Errorf("amount: want small on primary and large on secondary, got %q and %q", small, large)
+	}
+}
+
+func TestRouter_CapturesOnTheAuthorizingGateway(t *testing.T) {
+	primary, secondary := &StubGateway{}, &StubGateway{}
+	r := &Router{Primary: Route{"primary", primary}, Secondary: Route{"secondary", secondary}, Policy: PolicyAmount, AmountThresholdCents: 1000}
+
+	small, _ := r.Authorize(context.Background(), 499, "order-1")
+	large, _ := r.Authorize(context.Background(), 1999, "order-2")
+	if err := r.Capture(context.Background(), small); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if err := r.Void(context.Background(), large); err != nil {
+		t.Fatalf("void: %v", err)
+	}
+	if a := primary.Authorizations(); len(a) != 1 || a[0].State != AuthCaptured {
+		t.Errorf("primary authorizations: %+v", a)
+	}
+	if a := secondary.Authorizations(); len(a) != 1 || a[0].State != AuthVoided {
+		t.Errorf("secondary authorizations: %+v", a)
+	}
+	if err := r.Capture(context.Background(), "auth_missing"); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("unknown transaction: want ErrInvalidRequest, got %v", err)
+	}
+}
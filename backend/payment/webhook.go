@@ -0,0 +1,56 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrPending is returned by a gateway that accepted a charge but will confirm the outcome later,
+// through a webhook event. It is not a failure: the caller keeps the order pending.
+var ErrPending = errors.New("payment pending confirmation")
+
+// ErrInvalidSignature is returned when a webhook signature is missing or does not match.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// SignatureHeader carries the webhook signature: "sha256=" + hex(HMAC-SHA256(secret, body)).
+const SignatureHeader = "X-Webhook-Signature"
+
+// Webhook event types.
+const (
+	EventChargeSucceeded = "charge.succeeded"
+	EventChargeFailed    = "charge.failed"
+)
+
+// WebhookEvent is a provider notification about a charge. Data.IdempotencyKey is the key the charge
+// was requested with, which identifies our order.
+type WebhookEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		ChargeID       string `json:"chargeId"`
+		IdempotencyKey string `json:"idempotencyKey"`
+		AmountCents    int    `json:"amount"`
+		FailureReason  string `json:"failureReason,omitempty"`
+	} `json:"data"`
+}
+
+// SignWebhook returns the signature header value for body.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a signature header value against body in constant time.
+func VerifyWebhook(secret string, body []byte, signature string) error {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(SignWebhook(secret, body)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
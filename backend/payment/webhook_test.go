@@ -0,0 +1,35 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"charge.succeeded"}`)
+	sig := SignWebhook("whsec", body)
+	if err := VerifyWebhook("whsec", body, sig); err != nil {
+		t.Fatalf("valid signature: %v", err)
+	}
+	if err := VerifyWebhook("other", body, sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("wrong secret: want ErrInvalidSignature, got %v", err)
+	}
+	if err := VerifyWebhook("whsec", append(body, ' '), sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered body: want ErrInvalidSignature, got %v", err)
+	}
+	if err := VerifyWebhook("", body, SignWebhook("", body)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("no secret configured: want ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestHTTPGateway_AsyncProviderReturnsPending(t *testing.T) {
+	gw, fake := newFakeGateway(t)
+	fake.Async = true
+	if err := gw.Charge(context.Background(), 499, "async-1"); !errors.Is(err, ErrPending) {
+		t.Fatalf("want ErrPending, got %v", err)
+	}
+	if n := len(fake.Charges()); n != 1 {
+		t.Errorf("pending charge must be recorded once, got %d", n)
+	}
+}
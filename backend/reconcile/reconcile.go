@@ -0,0 +1,140 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"SnakeGame/ledger"
+	"SnakeGame/orders"
+	"SnakeGame/payment"
+)
+
+// Kind is the type of a reconciliation finding.
+type Kind string
+
+// Finding kinds.
+const (
+	KindOrphanCharge      Kind = "orphan_charge"       // the gateway charged a key no order was created with
+	KindPaidWithoutCharge Kind = "paid_without_charge" // the order was fulfilled (or credited in the ledger) but the gateway has no charge for it
+	KindAmountMismatch    Kind = "amount_mismatch"     // the gateway charged a different amount than the order's price
+	KindOverFulfilled     Kind = "over_fulfilled"      // a key's succeeded orders or credited coins exceed what its charges paid for
+)
+
+// Finding is one discrepancy between our records and the gateway's.
+type Finding struct {
+	Kind           Kind   `json:"kind"`
+	OrderID        string `json:"orderId,omitempty"`
+	ChargeID       string `json:"chargeId,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	Gateway        string `json:"gateway,omitempty"`
+	OrderCents     int    `json:"orderCents,omitempty"`   // what the order costs
+	ChargedCents   int    `json:"chargedCents,omitempty"` // what the gateway charged
+	CreditedCoins  int    `json:"creditedCoins,omitempty"`
+	Detail         string `json:"detail"`
+}
+
+// Report is the result of one reconciliation run.
+type Report struct {
+	RanAt    time.Time `json:"ranAt"`
+	Orders   int       `json:"orders"`
+	Charges  int       `json:"charges"`
+	Findings []Finding `json:"findings"`
+	OK       bool      `json:"ok"` // no findings
+}
+
+// Run compares orders and the ledger's coin pack credits against the charges the gateway lists.
+// Charges are matched to orders by idempotency key (the order's payment key). An order counts as
+// paid when it succeeded or the ledger credited coins for it; pending and failed orders need no charge.
+// Charges, succeeded orders and credited coins are counted per key, so one charge backing two
+// fulfilled orders (or two credits) is reported as over_fulfilled.
+func Run(ctx context.Context, gw payment.ChargeLister) (Report, error) {
+	charges, err := gw.ListCharges(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("list charges: %w", err)
+	}
+	all := orders.List()
+	credited := map[string]int{} // order id -> coins credited by the ledger
+	for _, e := range ledger.Entries() {
+		if e.Type == ledger.TypeCoinPack {
+			credited[e.Ref] += e.Amount
+		}
+	}
+	byKey := map[string]orders.Order{} // payment key -> latest order with it
+	for _, o := range all {
+		if o.PaymentKey != "" {
+			byKey[o.PaymentKey] = o
+		}
+	}
+
+	r := Report{RanAt: time.Now(), Orders: len(all), Charges: len(charges), Findings: []Finding{}}
+	charged := map[string]int{} // idempotency key -> charges the gateway made with it
+	for _, c := range charges {
+		o, ok := byKey[c.IdempotencyKey]
+		if c.IdempotencyKey == "" || !ok {
+			r.Findings = append(r.Findings, Finding{
+				Kind: KindOrphanCharge, ChargeID: c.ID, IdempotencyKey: c.IdempotencyKey, Gateway: c.Gateway, ChargedCents: c.AmountCents,
+				Detail: "charge has no matching order",
+			})
+			continue
+		}
+		charged[c.IdempotencyKey]++
+		if c.AmountCents != o.AmountCents {
+			r.Findings = append(r.Findings, Finding{
+				Kind: KindAmountMismatch, OrderID: o.ID, ChargeID: c.ID, IdempotencyKey: c.IdempotencyKey, Gateway: c.Gateway,
+				OrderCents: o.AmountCents, ChargedCents: c.AmountCents,
+				Detail: fmt.Sprintf("charged %d cents for an order of %d cents", c.AmountCents, o.AmountCents),
+			})
+		}
+	}
+	succeeded := map[string]int{} // payment key -> succeeded orders
+	keyCoins := map[string]int{}  // payment key -> coins credited for its orders
+	for _, o := range all {
+		coins := credited[o.ID]
+		if o.Status != orders.StatusSucceeded && coins == 0 {
+			continue
+		}
+		if o.Status == orders.StatusSucceeded {
+			succeeded[o.PaymentKey]++
+		}
+		keyCoins[o.PaymentKey] += coins
+		if charged[o.PaymentKey] == 0 {
+			r.Findings = append(r.Findings, Finding{
+				Kind: KindPaidWithoutCharge, OrderID: o.ID, IdempotencyKey: o.PaymentKey, Gateway: o.Gateway,
+				OrderCents: o.AmountCents, CreditedCoins: coins,
+				Detail: fmt.Sprintf("order is %s with %d coins credited but the gateway has no charge for it", o.Status, coins),
+			})
+		}
+	}
+	reported := map[string]bool{} // keys already reported as over-fulfilled
+	for _, c := range charges {
+		key, n := c.IdempotencyKey, charged[c.IdempotencyKey]
+		o, ok := byKey[key]
+		if !ok || reported[key] || (succeeded[key] <= n && keyCoins[key] <= n*o.Coins) {
+			continue
+		}
+		reported[key] = true
+		r.Findings = append(r.Findings, Finding{
+			Kind: KindOverFulfilled, OrderID: o.ID, IdempotencyKey: key, Gateway: o.Gateway,
+			OrderCents: o.AmountCents, CreditedCoins: keyCoins[key],
+			Detail: fmt.Sprintf("%d succeeded orders and %d coins credited for %d charge(s) of %d coins", succeeded[key], keyCoins[key], n, o.Coins),
+		})
+	}
+	r.OK = len(r.Findings) == 0
+	return r, nil
+}
+
+// WriteFile writes the report as indented JSON to path, replacing it atomically.
+func WriteFile(path string, r Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,94 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"SnakeGame/ledger"
+	"SnakeGame/orders"
+	"SnakeGame/payment"
+)
+
+// paidOrder creates an order for key, marks it succeeded and credits its coins in the ledger.
+func paidOrder(key string, cents int) orders.Order {
+	o, _ := orders.Create(orders.Order{PlayerID: "player1", PackID: "coins_500", Coins: 500, AmountCents: cents, PaymentKey: key})
+	orders.Transition(o.ID, orders.StatusSucceeded, "")
+	ledger.Record(ledger.TypeCoinPack, ledger.AccountPayments, "player1", 500, o.ID)
+	return o
+}
+
+func TestRun_ReportsDiscrepancies(t *testing.T) {
+	gw := &payment.StubGateway{}
+	ctx := context.Background()
+
+	paidOrder("k-clean", 499)
+	gw.Charge(ctx, 499, "k-clean")
+	unpaid := paidOrder("k-uncharged", 999)
+	mismatched := paidOrder("k-mismatch", 499)
+	gw.Charge(ctx, 599, "k-mismatch")
+	gw.Charge(ctx, 1999, "k-orphan")
+	orders.Create(orders.Order{PlayerID: "player1", PackID: "coins_500", Coins: 500, AmountCents: 499, PaymentKey: "k-pending"})
+
+	r, err := Run(ctx, gw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OK || r.Orders != 4 || r.Charges != 3 {
+		t.Fatalf("report totals: %+v", r)
+	}
+	want := map[Kind]string{ // kind -> order id or idempotency key
+		KindPaidWithoutCharge: unpaid.ID,
+		KindAmountMismatch:    mismatched.ID,
+		KindOrphanCharge:      "k-orphan",
+	}
+	if len(r.Findings) != len(want) {
+		t.Fatalf("want %d findings, got %+v", len(want), r.Findings)
+	}
+	for _, f := range r.Findings {
+		id := f.OrderID
+		if f.Kind == KindOrphanCharge {
+			id = f.IdempotencyKey
+		}
+		if want[f.Kind] != id {
+			t.Errorf("unexpected finding %+v", f)
+		}
+		if f.Kind == KindAmountMismatch && (f.OrderCents != 499 || f.ChargedCents != 599) {
+			t.Errorf("mismatch amounts: %+v", f)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := WriteFile(path, r); err != nil {
+		t.Fatal(err)
+	}
+	var saved Report
+	data, _ := os.ReadFile(path)
+	if json.Unmarshal(data, &saved) != nil || len(saved.Findings) != 3 {
+		t.Errorf("report file: %s", data)
+	}
+}
+
+func TestRun_ReportsOneChargeCreditedTwice(t *testing.T) {
+	gw := &payment.StubGateway{}
+	ctx := context.Background()
+	o := paidOrder("k-double", 499)
+	gw.Charge(ctx, 499, "k-double")
+	ledger.Record(ledger.TypeCoinPack, ledger.AccountPayments, "player1", 500, o.ID) // credited a second time
+
+	r, err := Run(ctx, gw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []Finding
+	for _, f := range r.Findings {
+		if f.IdempotencyKey == "k-double" {
+			found = append(found, f)
+		}
+	}
+	if r.OK || len(found) != 1 || found[0].Kind != KindOverFulfilled || found[0].OrderID != o.ID || found[0].CreditedCoins != 1000 {
+		t.Errorf("want one over_fulfilled finding with 1000 coins credited, got %+v", found)
+	}
+}
@@ -29,6 +29,12 @@ func IsNonRetryable(err error) bool {
 	return errors.As(err, &nr)
 }
 
+// DelayHinter is implemented by errors that say how long to wait before retrying, such as a
+// rate limit's Retry-After. Do waits at least that long before the next attempt.
+type DelayHinter interface {
+	RetryDelay() time.Duration
+}
+
 // Config holds retry behavior. Stop conditions: success, non-retryable error,
 // max attempts reached, or context cancelled.
 type Config struct {
@@ -51,6 +57,7 @@ func DefaultConfig() Config {
 // Do runs fn. On error, retries with exponential backoff until:
 // - fn returns nil (success),
 // - fn returns a non-retryable error (returned as-is),
+// - fn returns an error whose DelayHinter asks for more than MaxDelay (returned as-is),
 // - max attempts are reached (returns ErrMaxAttemptsExceeded wrapping last error),
 // - ctx is cancelled (returns ctx.Err() or last error).
 func Do(ctx context.Context, cfg Config, fn func() error) error {
@@ -81,10 +88,18 @@ func Do(ctx context.Context, cfg Config, fn func() error) error {
 		if attempt == cfg.MaxAttempts-1 {
 			break
 		}
+		wait := delay
+		var hint DelayHinter
+		if errors.As(lastErr, &hint) && hint.RetryDelay() > wait {
+			if hint.RetryDelay() > cfg.MaxDelay {
+				return lastErr // longer than we are willing to wait; the caller passes the hint on
+			}
+			wait = hint.RetryDelay()
+		}
 		select {
 		case <-ctx.Done():
 			return errors.Join(ctx.Err(), lastErr)
-		case <-time.After(delay):
+		case <-time.After(wait):
 			delay = time.Duration(float64(delay) * cfg.Multiplier)
 			if delay > cfg.MaxDelay {
 				delay = cfg.MaxDelay
@@ -126,3 +126,37 @@ func TestExponentialBackoffDelay(t *testing.T) {
 		t.Errorf("attempt 10: want max %v, got %v", max, d10)
 	}
 }
+
+// hintErr asks to be retried after d.
+type hintErr struct{ d time.Duration }
+
+func (e hintErr) Error() string             { return "rate limited" }
+func (e hintErr) RetryDelay() time.Duration { return e.d }
+
+func TestDo_HonorsDelayHint(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, Config{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Second}, func() error {
+		calls++
+		if calls == 1 {
+			return hintErr{d: 50 * time.Millisecond}
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("want success on the second call, got %v after %d calls", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("want the hinted 50ms wait, took %s", elapsed)
+	}
+
+	calls = 0
+	err = Do(ctx, Config{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}, func() error {
+		calls++
+		return hintErr{d: time.Minute}
+	})
+	if _, ok := err.(hintErr); !ok || calls != 1 {
+		t.Errorf("a hint beyond MaxDelay must stop retrying and return the error; got %v after %d calls", err, calls)
+	}
+}
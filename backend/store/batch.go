@@ -0,0 +1,134 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"SnakeGame/models"
+)
+
+// ErrUnknownOp is returned for a batch operation that is not add, update or remove.
+var ErrUnknownOp = errors.New("unknown batch operation")
+
+// LineQuantity is one entry of a whole-cart replacement.
+type LineQuantity struct {
+	ItemID   string `json:"itemId"`
+	Quantity int    `json:"quantity"`
+}
+
+// Batch operation names.
+const (
+	OpAdd    = "add"    // add Quantity (default 1) units of ItemID
+	OpUpdate = "update" // set line ID to Quantity (< 1 removes the line)
+	OpRemove = "remove" // remove line ID
+)
+
+// BatchOp is one operation of an all-or-nothing batch.
+type BatchOp struct {
+	Op       string `json:"op"`
+	ItemID   string `json:"itemId,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Quantity int    `json:"quantity,omitempty"`
+}
+
+// OpError reports which entry of a replacement or batch failed validation.
+type OpError struct {
+	Index int
+	Err   error
+}
+
+func (e *OpError) Error() string { return fmt.Sprintf("operation %d: %v", e.Index, e.Err) }
+
+func (e *OpError) Unwrap() error { return e.Err }
+
+// ReplaceCart atomically replaces the whole cart with the given lines, applying the same rules as
+// AddToCart. Lines for items already in the cart keep their line id. Nothing changes on error.
+func ReplaceCart(lines []LineQuantity, owned []string) error {
+	return mutateCart(nil, func(current []models.CartItem) ([]models.CartItem, error) {
+		return replaceLines(current, lines, owned)
+	})
+}
+
+// ReplaceCartIfMatch is ReplaceCart that fails with ErrVersionMismatch unless the cart is still at expected.
+func ReplaceCartIfMatch(lines []LineQuantity, owned []string, expected uint64) error {
+	return mutateCart(&expected, func(current []models.CartItem) ([]models.CartItem, error) {
+		return replaceLines(current, lines, owned)
+	})
+}
+
+// ApplyBatch applies add, update and remove operations in order, all or nothing: if any operation
+// fails validation the cart is left untouched and an *OpError identifies it.
+func ApplyBatch(ops []BatchOp, owned []string) error {
+	return mutateCart(nil, func(current []models.CartItem) ([]models.CartItem, error) {
+		return applyOps(current, ops, owned)
+	})
+}
+
+// ApplyBatchIfMatch is ApplyBatch that fails with ErrVersionMismatch unless the cart is still at expected.
+func ApplyBatchIfMatch(ops []BatchOp, owned []string, expected uint64) error {
+	return mutateCart(&expected, func(current []models.CartItem) ([]models.CartItem, error) {
+		return applyOps(current, ops, owned)
+	})
+}
+
+// mutateCart runs fn on a copy of the cart and swaps the result in only if fn succeeds.
+func mutateCart(expected *uint64, fn func([]models.CartItem) ([]models.CartItem, error)) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if expected != nil && *expected != version {
+		return ErrVersionMismatch
+	}
+	working := make([]models.CartItem, len(cart))
+	copy(working, cart)
+	next, err := fn(working)
+	if err != nil {
+		return err
+	}
+	cart = next
+	markChanged()
+	return nil
+}
+
+// replaceLines builds a new cart from lines, reusing line ids of current. Callers hold mu.
+func replaceLines(current []models.CartItem, lines []LineQuantity, owned []string) ([]models.CartItem, error) {
+	var next []models.CartItem
+	for i, l := range lines {
+		var err error
+		if next, err = addItem(next, l.ItemID, l.Quantity, owned); err != nil {
+			return nil, &OpError{Index: i, Err: err}
+		}
+	}
+	for i := range next {
+		for _, old := range current {
+			if old.ItemID == next[i].ItemID {
+				next[i].ID = old.ID
+			}
+		}
+	}
+	return next, nil
+}
+
+// applyOps applies batch operations to lines in order. Callers hold mu.
+func applyOps(lines []models.CartItem, ops []BatchOp, owned []string) ([]models.CartItem, error) {
+	for i, op := range ops {
+		var err error
+		switch op.Op {
+		case OpAdd:
+			quantity := op.Quantity
+			if quantity == 0 {
+				quantity = 1
+			}
+			lines, err = addItem(lines, op.ItemID, quantity, owned)
+		case OpUpdate:
+			lines, err = updateLine(lines, op.ID, op.Quantity)
+		case OpRemove:
+			lines, err = updateLine(lines, op.ID, 0)
+		default:
+			err = ErrUnknownOp
+		}
+		if err != nil {
+			return nil, &OpError{Index: i, Err: err}
+		}
+	}
+	return lines, nil
+}
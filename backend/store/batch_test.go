@@ -0,0 +1,66 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyBatch_AllOrNothing(t *testing.T) {
+	ClearCart()
+	t.Cleanup(ClearCart)
+	AddToCart("extra_life", nil)
+	items, _, before := GetCartWithVersion()
+	lifeLine := items[0].ID
+
+	err := ApplyBatch([]BatchOp{
+		{Op: OpAdd, ItemID: "skin_gold"},
+		{Op: OpUpdate, ID: lifeLine, Quantity: 3},
+		{Op: OpAdd, ItemID: "skin_gold"}, // second Gold exceeds the skin cap
+	}, nil)
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Index != 2 || !errors.Is(err, ErrQuantityLimit) {
+		t.Fatalf("want OpError at index 2 wrapping ErrQuantityLimit, got %v", err)
+	}
+	after, total, version := GetCartWithVersion()
+	if version != before || len(after) != 1 || total != 50 {
+		t.Fatalf("failed batch must leave the cart untouched: %d lines, total %d, version %d->%d", len(after), total, before, version)
+	}
+
+	if err := ApplyBatch([]BatchOp{
+		{Op: OpAdd, ItemID: "skin_gold"},
+		{Op: OpUpdate, ID: lifeLine, Quantity: 3},
+	}, nil); err != nil {
+		t.Fatalf("ApplyBatch: %v", err)
+	}
+	if _, total := GetCart(); total != 250 {
+		t.Errorf("total after batch: want 250, got %d", total)
+	}
+	if err := ApplyBatch([]BatchOp{{Op: OpRemove, ID: lifeLine}}, nil); err != nil || CartCount() != 1 {
+		t.Errorf("remove op: err=%v lines=%d", err, CartCount())
+	}
+}
+
+func TestReplaceCart(t *testing.T) {
+	ClearCart()
+	t.Cleanup(ClearCart)
+	AddToCart("extra_life", nil)
+	items, _ := GetCart()
+	lifeLine := items[0].ID
+
+	if err := ReplaceCart([]LineQuantity{{ItemID: "extra_life", Quantity: 2}, {ItemID: "skin_ice", Quantity: 1}}, nil); err != nil {
+		t.Fatalf("ReplaceCart: %v", err)
+	}
+	items, total := GetCart()
+	if len(items) != 2 || total != 200 || items[0].ID != lifeLine {
+		t.Errorf("unexpected cart after replace: %+v total %d", items, total)
+	}
+	if err := ReplaceCart([]LineQuantity{{ItemID: "skin_ice", Quantity: 1}}, []string{"skin_ice"}); !errors.Is(err, ErrAlreadyOwned) {
+		t.Errorf("owned skin: want ErrAlreadyOwned, got %v", err)
+	}
+	if err := ReplaceCartIfMatch(nil, nil, CartVersion()+1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("stale version: want ErrVersionMismatch, got %v", err)
+	}
+	if err := ReplaceCart(nil, nil); err != nil || CartCount() != 0 {
+		t.Errorf("empty replace should clear the cart: err=%v lines=%d", err, CartCount())
+	}
+}
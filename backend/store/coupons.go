@@ -0,0 +1,271 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"SnakeGame/models"
+)
+
+// ErrInvalidCoupon is returned when a coupon definition is malformed.
+var ErrInvalidCoupon = errors.New("invalid coupon")
+
+// ErrCouponExists is returned when creating a coupon whose code is already taken.
+var ErrCouponExists = errors.New("coupon code already exists")
+
+// ErrCouponNotFound is returned when a code does not match any coupon.
+var ErrCouponNotFound = errors.New("coupon not found")
+
+// ErrCouponExpired is returned when a coupon is past its expiry.
+var ErrCouponExpired = errors.New("coupon expired")
+
+// ErrCouponExhausted is returned when a coupon reached its global redemption cap.
+var ErrCouponExhausted = errors.New("coupon redemption limit reached")
+
+// ErrCouponAlreadyUsed is returned when the player already redeemed the coupon.
+var ErrCouponAlreadyUsed = errors.New("coupon already used")
+
+// ErrCouponNotApplicable is returned when no cart line is eligible for the coupon.
+var ErrCouponNotApplicable = errors.New("coupon does not apply to any item in the cart")
+
+// ErrNoCoupon is returned when removing a coupon from a cart that has none.
+var ErrNoCoupon = errors.New("no coupon attached to cart")
+
+// Coupon is a promo code. Exactly one of PercentOff or AmountOff is set. When ItemIDs or Kind is
+// set, only matching lines are discounted. MaxRedemptions of 0 means no global cap; every player
+// may redeem a coupon once.
+type Coupon struct {
+	Code           string           `json:"code"`
+	PercentOff     int              `json:"percentOff,omitempty"`
+	AmountOff      int              `json:"amountOff,omitempty"`
+	ItemIDs        []string         `json:"itemIds,omitempty"`
+	Kind           *models.ItemKind `json:"kind,omitempty"`
+	ExpiresAt      *time.Time       `json:"expiresAt,omitempty"`
+	MaxRedemptions int              `json:"maxRedemptions,omitempty"`
+	Redemptions    int              `json:"redemptions"`
+}
+
+// couponState tracks redemptions and in-flight checkouts holding the coupon.
+type couponState struct {
+	Coupon
+	reserved   map[string]bool // player ids with a checkout in progress
+	redeemedBy map[string]bool // player ids that completed a checkout with the coupon
+}
+
+var (
+	couponMu   sync.Mutex                      // protects coupons and cartCoupon; taken after mu (ApplyCoupon), never before it
+	coupons    = make(map[string]*couponState) // code -> coupon
+	cartCoupon string                          // code attached to the cart ("" for none)
+)
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// CreateCoupon validates and stores a coupon. Codes are case-insensitive.
+func CreateCoupon(c Coupon) (Coupon, error) {
+	c.Code = normalizeCode(c.Code)
+	switch {
+	case c.Code == "":
+		return Coupon{}, errors.Join(ErrInvalidCoupon, errors.New("code is required"))
+	case (c.PercentOff == 0) == (c.AmountOff == 0):
+		return Coupon{}, errors.Join(ErrInvalidCoupon, errors.New("exactly one of percentOff or amountOff is required"))
+	case c.PercentOff < 0 || c.PercentOff > 100:
+		return Coupon{}, errors.Join(ErrInvalidCoupon, errors.New("percentOff must be between 1 and 100"))
+	case c.AmountOff < 0:
+		return Coupon{}, errors.Join(ErrInvalidCoupon, errors.New("amountOff must not be negative"))
+	case c.MaxRedemptions < 0:
+		return Coupon{}, errors.Join(ErrInvalidCoupon, errors.New("maxRedemptions must not be negative"))
+	}
+	for _, id := range c.ItemIDs {
+		if _, ok := models.ItemPrice(id); !ok {
+			return Coupon{}, errors.Join(ErrInvalidCoupon, errors.New("unknown item "+id))
+		}
+	}
+	c.ItemIDs = append([]string(nil), c.ItemIDs...)
+	c.Redemptions = 0
+	couponMu.Lock()
+	defer couponMu.Unlock()
+	if _, exists := coupons[c.Code]; exists {
+		return Coupon{}, ErrCouponExists
+	}
+	coupons[c.Code] = &couponState{Coupon: c, reserved: map[string]bool{}, redeemedBy: map[string]bool{}}
+	return c, nil
+}
+
+// Coupons returns a copy of every coupon with its redemption count.
+func Coupons() []Coupon {
+	couponMu.Lock()
+	defer couponMu.Unlock()
+	out := make([]Coupon, 0, len(coupons))
+	for _, c := range coupons {
+		out = append(out, c.Coupon)
+	}
+	return out
+}
+
+// usable checks expiry, the global cap (counting in-flight checkouts) and single use. Callers hold couponMu.
+func (c *couponState) usable(playerID string) error {
+	if c.ExpiresAt != nil && !time.Now().Before(*c.ExpiresAt) {
+		return ErrCouponExpired
+	}
+	if c.redeemedBy[playerID] {
+		return ErrCouponAlreadyUsed
+	}
+	if c.MaxRedemptions > 0 && c.Redemptions+len(c.reserved) >= c.MaxRedemptions && !c.reserved[playerID] {
+		return ErrCouponExhausted
+	}
+	return nil
+}
+
+// appliesTo reports whether the coupon discounts the given cart line.
+func (c Coupon) appliesTo(it models.CartItem) bool {
+	if len(c.ItemIDs) > 0 {
+		for _, id := range c.ItemIDs {
+			if id == it.ItemID {
+				return true
+			}
+		}
+		return false
+	}
+	return c.Kind == nil || *c.Kind == it.Kind
+}
+
+// Discount returns the coins taken off the given lines: a percentage of the eligible subtotal, or
+// a fixed amount capped at the eligible subtotal.
+func (c Coupon) Discount(lines []models.CartItem) int {
+	var eligible int
+	for _, it := range lines {
+		if c.appliesTo(it) {
+			eligible += it.Price * it.Quantity
+		}
+	}
+	if c.PercentOff > 0 {
+		return eligible * c.PercentOff / 100
+	}
+	if c.AmountOff > eligible {
+		return eligible
+	}
+	return c.AmountOff
+}
+
+// ApplyCoupon attaches a code to the cart after checking it is usable by the player and discounts
+// at least one line. A previously attached code is replaced. The cart is locked for the whole call,
+// so the check runs against the cart the code is attached to.
+func ApplyCoupon(code, playerID string) (Coupon, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	couponMu.Lock()
+	c, ok := coupons[normalizeCode(code)]
+	err := ErrCouponNotFound
+	if ok {
+		err = c.usable(playerID)
+		if err == nil && c.Discount(cart) == 0 {
+			err = ErrCouponNotApplicable
+		}
+	}
+	var applied Coupon
+	if err == nil {
+		cartCoupon = c.Code
+		applied = c.Coupon
+	}
+	couponMu.Unlock()
+	if err != nil {
+		return Coupon{}, err
+	}
+	markChanged()
+	return applied, nil
+}
+
+// RemoveCoupon detaches the code from the cart.
+func RemoveCoupon() error {
+	couponMu.Lock()
+	if cartCoupon == "" {
+		couponMu.Unlock()
+		return ErrNoCoupon
+	}
+	cartCoupon = ""
+	couponMu.Unlock()
+	touch()
+	return nil
+}
+
+// detachCoupon drops the cart's coupon without touching the cart version (the caller already did).
+func detachCoupon() {
+	couponMu.Lock()
+	defer couponMu.Unlock()
+	cartCoupon = ""
+}
+
+// CartCoupon returns the coupon attached to the cart, if any.
+func CartCoupon() (Coupon, bool) {
+	couponMu.Lock()
+	defer couponMu.Unlock()
+	c, ok := coupons[cartCoupon]
+	if !ok {
+		return Coupon{}, false
+	}
+	return c.Coupon, true
+}
+
+// CheckCoupon reports whether the player could redeem the code right now, without reserving it.
+func CheckCoupon(code, playerID string) error {
+	couponMu.Lock()
+	defer couponMu.Unlock()
+	c, ok := coupons[normalizeCode(code)]
+	if !ok {
+		return ErrCouponNotFound
+	}
+	return c.usable(playerID)
+}
+
+// ReserveCoupon holds one redemption for the player while a checkout is in flight, so concurrent
+// checkouts cannot exceed the global cap. Pair with CommitCoupon or ReleaseCoupon.
+func ReserveCoupon(code, playerID string) (Coupon, error) {
+	couponMu.Lock()
+	defer couponMu.Unlock()
+	c, ok := coupons[normalizeCode(code)]
+	if !ok {
+		return Coupon{}, ErrCouponNotFound
+	}
+	if c.reserved[playerID] {
+		return Coupon{}, ErrCouponAlreadyUsed
+	}
+	if err := c.usable(playerID); err != nil {
+		return Coupon{}, err
+	}
+	c.reserved[playerID] = true
+	return c.Coupon, nil
+}
+
+// CommitCoupon counts the redemption after a successful checkout and detaches the code from the cart.
+func CommitCoupon(code, playerID string) {
+	couponMu.Lock()
+	c, ok := coupons[normalizeCode(code)]
+	if !ok || !c.reserved[playerID] {
+		couponMu.Unlock()
+		return
+	}
+	delete(c.reserved, playerID)
+	c.redeemedBy[playerID] = true
+	c.Redemptions++
+	detached := cartCoupon == c.Code
+	if detached {
+		cartCoupon = ""
+	}
+	couponMu.Unlock()
+	if detached {
+		touch() // after couponMu is released: mu is never taken while holding couponMu
+	}
+}
+
+// ReleaseCoupon drops the player's reservation after a failed checkout; nothing is counted.
+func ReleaseCoupon(code, playerID string) {
+	couponMu.Lock()
+	defer couponMu.Unlock()
+	if c, ok := coupons[normalizeCode(code)]; ok {
+		delete(c.reserved, playerID)
+	}
+}
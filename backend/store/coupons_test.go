@@ -0,0 +1,78 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"SnakeGame/models"
+)
+
+func TestCoupon_Discount(t *testing.T) {
+	skin := models.ItemKindSkin
+	lines := []models.CartItem{
+		{ItemID: "skin_gold", Price: 100, Quantity: 1, Kind: models.ItemKindSkin},
+		{ItemID: "extra_life", Price: 50, Quantity: 2, Kind: models.ItemKindLife},
+	}
+	cases := []struct {
+		name string
+		c    Coupon
+		want int
+	}{
+		{"percent on everything", Coupon{PercentOff: 10}, 20},
+		{"percent on skins", Coupon{PercentOff: 50, Kind: &skin}, 50},
+		{"fixed on one item", Coupon{AmountOff: 30, ItemIDs: []string{"extra_life"}}, 30},
+		{"fixed capped at eligible subtotal", Coupon{AmountOff: 500, ItemIDs: []string{"skin_gold"}}, 100},
+		{"no eligible lines", Coupon{AmountOff: 10, ItemIDs: []string{"skin_ice"}}, 0},
+	}
+	for _, tc := range cases {
+		if got := tc.c.Discount(lines); got != tc.want {
+			t.Errorf("%s: want %d, got %d", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestCoupon_ReserveCommitRelease(t *testing.T) {
+	if _, err := CreateCoupon(Coupon{Code: "once", AmountOff: 10, MaxRedemptions: 1}); err != nil {
+		t.Fatalf("CreateCoupon: %v", err)
+	}
+	if _, err := ReserveCoupon("ONCE", "p1"); err != nil {
+		t.Fatalf("first reserve: %v", err)
+	}
+	// The in-flight reservation counts against the global cap.
+	if _, err := ReserveCoupon("once", "p2"); !errors.Is(err, ErrCouponExhausted) {
+		t.Fatalf("second player while reserved: want ErrCouponExhausted, got %v", err)
+	}
+	ReleaseCoupon("once", "p1")
+	if _, err := ReserveCoupon("once", "p2"); err != nil {
+		t.Fatalf("reserve after release: %v", err)
+	}
+	CommitCoupon("once", "p2")
+	if _, err := ReserveCoupon("once", "p2"); !errors.Is(err, ErrCouponAlreadyUsed) {
+		t.Errorf("same player after commit: want ErrCouponAlreadyUsed, got %v", err)
+	}
+	if _, err := ReserveCoupon("once", "p3"); !errors.Is(err, ErrCouponExhausted) {
+		t.Errorf("cap reached: want ErrCouponExhausted, got %v", err)
+	}
+	for _, c := range Coupons() {
+		if c.Code == "ONCE" && c.Redemptions != 1 {
+			t.Errorf("redemptions: want 1, got %d", c.Redemptions)
+		}
+	}
+}
+
+func TestCoupon_ExpiredAndInvalid(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	if _, err := CreateCoupon(Coupon{Code: "old", PercentOff: 10, ExpiresAt: &past}); err != nil {
+		t.Fatalf("CreateCoupon: %v", err)
+	}
+	if _, err := ReserveCoupon("old", "p1"); !errors.Is(err, ErrCouponExpired) {
+		t.Errorf("want ErrCouponExpired, got %v", err)
+	}
+	if _, err := CreateCoupon(Coupon{Code: "old", PercentOff: 5}); !errors.Is(err, ErrCouponExists) {
+		t.Errorf("duplicate code: want ErrCouponExists, got %v", err)
+	}
+	if _, err := CreateCoupon(Coupon{Code: "both", PercentOff: 5, AmountOff: 5}); !errors.Is(err, ErrInvalidCoupon) {
+		t.Errorf("both discounts: want ErrInvalidCoupon, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"SnakeGame/models"
+)
+
+// DefaultCartTTL is how long an untouched cart is kept unless configured otherwise.
+const DefaultCartTTL = 24 * time.Hour
+
+// cartTTL is measured from the last cart modification. Protected by mu.
+var cartTTL = DefaultCartTTL
+
+// SetCartTTL configures how long an idle cart lives (values <= 0 are ignored).
+func SetCartTTL(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	cartTTL = d
+}
+
+// CartExpiresAt returns when the cart will expire if left untouched. ok is false for an empty cart.
+func CartExpiresAt() (expiresAt time.Time, ok bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(cart) == 0 {
+		return time.Time{}, false
+	}
+	return modifiedAt.Add(cartTTL), true
+}
+
+// EventType names a cart lifecycle event.
+type EventType string
+
+// EventCartExpired is emitted when an idle cart is discarded (abandoned-cart analytics).
+const EventCartExpired EventType = "cart.expired"
+
+// Event describes something that happened to a cart. Items and Total are the cart contents at the time.
+// GuestID is set for guest carts and empty for the account cart.
+type Event struct {
+	Type         EventType         `json:"type"`
+	GuestID      string            `json:"guestId,omitempty"`
+	At           time.Time         `json:"at"`
+	Items        []models.CartItem `json:"items"`
+	Total        int               `json:"total"`
+	LastModified time.Time         `json:"lastModified"`
+}
+
+var (
+	listenersMu sync.RWMutex  // protects listeners
+	listeners   []func(Event) // called synchronously for every event, outside the cart lock
+)
+
+// OnEvent registers fn to receive cart events. Listeners must not block for long.
+func OnEvent(fn func(Event)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// emit delivers an event to every listener. Must be called without holding mu.
+func emit(e Event) {
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(e)
+	}
+}
+
+// ExpireIdleCarts discards the account cart and any guest carts that have not been modified for the
+// TTL, detaching the account cart's coupon and emitting EventCartExpired for each. Returns the number
+// of carts expired.
+func ExpireIdleCarts() int {
+	var expired []Event
+	accountExpired := false
+	mu.Lock()
+	t := now()
+	if len(cart) > 0 && !t.Before(modifiedAt.Add(cartTTL)) {
+		expired = append(expired, expiredEvent(t, "", cart, modifiedAt))
+		cart = nil
+		markChanged()
+		accountExpired = true
+	}
+	for id, g := range guestCarts {
+		if !t.Before(g.modifiedAt.Add(cartTTL)) {
+			expired = append(expired, expiredEvent(t, id, g.items, g.modifiedAt))
+			delete(guestCarts, id)
+		}
+	}
+	mu.Unlock()
+
+	if accountExpired {
+		detachCoupon()
+	}
+	for _, e := range expired {
+		emit(e)
+	}
+	return len(expired)
+}
+
+// expiredEvent builds the EventCartExpired for a discarded cart.
+func expiredEvent(at time.Time, guestID string, items []models.CartItem, lastModified time.Time) Event {
+	e := Event{Type: EventCartExpired, GuestID: guestID, At: at, Items: items, LastModified: lastModified}
+	for _, it := range items {
+		e.Total += it.Price * it.Quantity
+	}
+	return e
+}
+
+// StartExpiry runs ExpireIdleCarts every interval until ctx is cancelled.
+func StartExpiry(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ExpireIdleCarts()
+			}
+		}
+	}()
+}
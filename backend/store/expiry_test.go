@@ -0,0 +1,44 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpireIdleCarts(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	prevNow := now
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = prevNow; SetCartTTL(DefaultCartTTL); ClearCart() })
+	SetCartTTL(time.Hour)
+	ClearCart()
+
+	var got []Event
+	OnEvent(func(e Event) { got = append(got, e) })
+
+	AddToCart("extra_life", nil)
+	if exp, ok := CartExpiresAt(); !ok || !exp.Equal(clock.Add(time.Hour)) {
+		t.Fatalf("expiresAt: want %v, got %v (ok=%v)", clock.Add(time.Hour), exp, ok)
+	}
+
+	clock = clock.Add(50 * time.Minute)
+	AddToCart("extra_life", nil) // modification restarts the TTL
+	clock = clock.Add(30 * time.Minute)
+	if n := ExpireIdleCarts(); n != 0 {
+		t.Fatalf("cart touched 30m ago must not expire, expired %d", n)
+	}
+
+	clock = clock.Add(30 * time.Minute)
+	if n := ExpireIdleCarts(); n != 1 {
+		t.Fatalf("idle cart should expire, expired %d", n)
+	}
+	if CartCount() != 0 {
+		t.Error("expired cart must be empty")
+	}
+	if len(got) != 1 || got[0].Type != EventCartExpired || got[0].Total != 100 || len(got[0].Items) != 1 {
+		t.Errorf("unexpected events: %+v", got)
+	}
+	if _, ok := CartExpiresAt(); ok {
+		t.Error("empty cart has no expiry")
+	}
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"SnakeGame/models"
+)
+
+// ErrGuestCartNotFound is returned when a guest cart id is unknown (never created, merged or expired).
+var ErrGuestCartNotFound = errors.New("guest cart not found")
+
+// guestCart is the cart of a visitor who has not signed in yet. Protected by mu.
+type guestCart struct {
+	items      []models.CartItem
+	modifiedAt time.Time
+}
+
+// guestCarts holds guest carts by guest id. Protected by mu.
+var guestCarts = map[string]*guestCart{}
+
+// NewGuestCart creates an empty guest cart and returns its id.
+func NewGuestCart() string {
+	id := newCartLineID()
+	mu.Lock()
+	defer mu.Unlock()
+	guestCarts[id] = &guestCart{modifiedAt: now()}
+	return id
+}
+
+// AddToGuestCart adds one unit of an item to a guest cart with the same rules as AddToCart
+// (a guest owns nothing, so ownership is checked when the cart is merged).
+func AddToGuestCart(guestID, itemID string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	g, ok := guestCarts[guestID]
+	if !ok {
+		return ErrGuestCartNotFound
+	}
+	lines, err := addItem(g.items, itemID, 1, nil)
+	if err != nil {
+		return err
+	}
+	g.items = lines
+	g.modifiedAt = now()
+	return nil
+}
+
+// GuestCart returns a copy of a guest cart and its total.
+func GuestCart(guestID string) ([]models.CartItem, int, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	g, ok := guestCarts[guestID]
+	if !ok {
+		return nil, 0, ErrGuestCartNotFound
+	}
+	out := make([]models.CartItem, len(g.items))
+	var total int
+	for i, it := range g.items {
+		out[i] = it
+		total += it.Price * it.Quantity
+	}
+	return out, total, nil
+}
+
+// MergedLine is a guest line (or part of one) that moved into the account cart.
+type MergedLine struct {
+	ItemID   string `json:"itemId"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+// DroppedLine is a guest line (or part of one) that was discarded, with the reason.
+type DroppedLine struct {
+	ItemID   string `json:"itemId"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+}
+
+// MergeReport lists what a guest-cart merge kept and dropped.
+type MergeReport struct {
+	Merged  []MergedLine  `json:"merged"`
+	Dropped []DroppedLine `json:"dropped"`
+}
+
+// MergeGuestCart moves a guest cart into the account cart when the player signs in, then discards
+// the guest cart. Lines are deduplicated by ItemID against the account cart, already-owned skins
+// are dropped, and quantities are clamped to the per-kind caps and remaining stock.
+func MergeGuestCart(guestID string, owned []string) (MergeReport, error) {
+	report := MergeReport{Merged: []MergedLine{}, Dropped: []DroppedLine{}}
+	mu.Lock()
+	defer mu.Unlock()
+	g, ok := guestCarts[guestID]
+	if !ok {
+		return report, ErrGuestCartNotFound
+	}
+	delete(guestCarts, guestID)
+
+	merged := false
+	for _, it := range g.items {
+		if it.Kind == models.ItemKindSkin && inLines(cart, it.ItemID) {
+			report.Dropped = append(report.Dropped, DroppedLine{ItemID: it.ItemID, Name: it.Name, Quantity: it.Quantity, Reason: "already in cart"})
+			continue
+		}
+		// Add as many units as the cart rules allow, one at a time, so caps and stock clamp the line.
+		added := 0
+		var lastErr error
+		for added < it.Quantity {
+			lines, err := addItem(cart, it.ItemID, 1, owned)
+			if err != nil {
+				lastErr = err
+				break
+			}
+			cart = lines
+			added++
+		}
+		if added > 0 {
+			merged = true
+			report.Merged = append(report.Merged, MergedLine{ItemID: it.ItemID, Name: it.Name, Quantity: added})
+		}
+		if added < it.Quantity {
+			report.Dropped = append(report.Dropped, DroppedLine{ItemID: it.ItemID, Name: it.Name, Quantity: it.Quantity - added, Reason: mergeDropReason(lastErr)})
+		}
+	}
+	if merged {
+		markChanged()
+	}
+	return report, nil
+}
+
+// inLines reports whether lines already hold itemID.
+func inLines(lines []models.CartItem, itemID string) bool {
+	for _, it := range lines {
+		if it.ItemID == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeDropReason turns a cart rule error into a short reason for the merge report.
+func mergeDropReason(err error) string {
+	switch {
+	case errors.Is(err, ErrAlreadyOwned):
+		return "already owned"
+	case errors.Is(err, ErrQuantityLimit):
+		return "quantity limit reached"
+	case errors.Is(err, ErrSoldOut):
+		return "sold out"
+	case errors.Is(err, ErrUnknownItem):
+		return "no longer available"
+	case err != nil:
+		return err.Error()
+	}
+	return "not merged"
+}
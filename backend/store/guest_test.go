@@ -0,0 +1,47 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMergeGuestCart(t *testing.T) {
+	ClearCart()
+	t.Cleanup(ClearCart)
+	t.Cleanup(func() { SetMaxConsumableQuantity(DefaultMaxConsumableQuantity) })
+	SetMaxConsumableQuantity(3)
+
+	AddToCart("skin_ice", nil)
+	AddToCart("extra_life", nil)
+	AddToCart("extra_life", nil)
+
+	guest := NewGuestCart()
+	for _, id := range []string{"skin_ice", "skin_gold", "skin_fire", "extra_life", "extra_life"} {
+		if err := AddToGuestCart(guest, id); err != nil {
+			t.Fatalf("AddToGuestCart(%s): %v", id, err)
+		}
+	}
+
+	report, err := MergeGuestCart(guest, []string{"default", "skin_gold"})
+	if err != nil {
+		t.Fatalf("MergeGuestCart: %v", err)
+	}
+	reasons := map[string]string{}
+	for _, d := range report.Dropped {
+		reasons[d.ItemID] = d.Reason
+	}
+	if reasons["skin_ice"] != "already in cart" || reasons["skin_gold"] != "already owned" || reasons["extra_life"] != "quantity limit reached" {
+		t.Errorf("unexpected drops: %+v", report.Dropped)
+	}
+	if len(report.Merged) != 2 {
+		t.Errorf("want skin_fire and one extra_life merged, got %+v", report.Merged)
+	}
+
+	items, total := GetCart()
+	if len(items) != 3 || total != 100+100+3*50 {
+		t.Errorf("account cart after merge: %d lines totalling %d", len(items), total)
+	}
+	if _, _, err := GuestCart(guest); !errors.Is(err, ErrGuestCartNotFound) {
+		t.Errorf("guest cart must be discarded after merge, got %v", err)
+	}
+}
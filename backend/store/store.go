@@ -4,60 +4,195 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"SnakeGame/models"
 )
 
 var (
-	mu   sync.RWMutex // protects the cart field (cart items)
-	cart []models.CartItem // cart items
+	mu      sync.RWMutex // protects the cart field (cart items) and version
+	cart    []models.CartItem // cart items
+	version uint64 // bumped on every cart change; exposed to clients as the cart ETag
+	modifiedAt time.Time // time of the last cart change; the cart expires cartTTL after it
+	now = time.Now // clock used for expiry; replaced in tests
 )
 
+// cartChanged is signalled without blocking on every cart change; see CartChanged.
+var cartChanged = make(chan struct{}, 1)
+
+// markChanged records a cart change. Callers hold mu.
+func markChanged() {
+	version++
+	modifiedAt = now()
+	select {
+	case cartChanged <- struct{}{}:
+	default: // a signal is already pending; the receiver will read the latest cart anyway
+	}
+}
+
+// CartChanged returns a channel that receives after the account cart changes. Bursts of changes are
+// coalesced into one signal, so the receiver should read the current cart rather than count signals.
+// Meant for a single consumer (the live-update feed).
+func CartChanged() <-chan struct{} {
+	return cartChanged
+}
+
 // ErrUnknownItem is returned when adding an item not in the catalog.
 var ErrUnknownItem = errors.New("unknown item")
 
 // ErrDefaultSkin is returned when trying to add the free default skin to cart.
 var ErrDefaultSkin = errors.New("default skin cannot be purchased")
 
+// ErrSoldOut is returned when a limited item has no stock left for another unit.
+var ErrSoldOut = models.ErrSoldOut
+
 // ErrCartItemNotFound is returned when a cart line id is not found.
 var ErrCartItemNotFound = errors.New("cart item not found")
 
+// ErrAlreadyOwned is returned when adding a skin the player already owns.
+var ErrAlreadyOwned = errors.New("skin already owned")
+
+// ErrQuantityLimit is returned when a line would exceed the per-kind quantity cap.
+var ErrQuantityLimit = errors.New("quantity limit exceeded")
+
+// DefaultMaxConsumableQuantity is the per-line cap for consumables unless configured otherwise.
+const DefaultMaxConsumableQuantity = 10
+
+// maxConsumableQuantity caps consumable lines (e.g. extra lives); skins are always capped at 1. Protected by mu.
+var maxConsumableQuantity = DefaultMaxConsumableQuantity
+
+// SetMaxConsumableQuantity configures the per-line cap for consumables (values < 1 are ignored).
+func SetMaxConsumableQuantity(n int) {
+	if n < 1 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	maxConsumableQuantity = n
+}
+
+// maxQuantity returns the per-line cap for an item kind. Callers hold mu.
+func maxQuantity(kind models.ItemKind) int {
+	if kind == models.ItemKindSkin {
+		return 1
+	}
+	return maxConsumableQuantity
+}
+
+// checkQuantity validates a line quantity against the per-kind cap. Callers hold mu.
+func checkQuantity(it models.CartItem, quantity int) error {
+	if limit := maxQuantity(it.Kind); quantity > limit {
+		return fmt.Errorf("%w: at most %d of %s per cart", ErrQuantityLimit, limit, it.Name)
+	}
+	return nil
+}
+
+// ErrInvalidQuantity is returned when an added quantity is not positive.
+var ErrInvalidQuantity = errors.New("quantity must be at least 1")
+
+// ErrVersionMismatch is returned by conditional updates when the cart changed since the caller read it.
+var ErrVersionMismatch = errors.New("cart has been modified")
+
 func newCartLineID() string {
 	b := make([]byte, 8)
 	rand.Read(b)
 	return hex.EncodeToString(b)
 }
 
-// AddToCart adds one unit of an item to the cart. If the same item already exists as a line, quantity is incremented.
-func AddToCart(itemID string) error {
+// AddToCart adds one unit of an item to the cart at its current effective price. If the same item already exists as a line, quantity is incremented.
+// Limited items are rejected with ErrSoldOut once the cart would hold more units than remain in stock.
+// Skins in owned are rejected with ErrAlreadyOwned; lines are capped per kind (ErrQuantityLimit).
+func AddToCart(itemID string, owned []string) error {
+	mu.Lock() // protect the cart field
+	defer mu.Unlock() // unlock the cart field
+	lines, err := addItem(cart, itemID, 1, owned)
+	if err != nil {
+		return err
+	}
+	cart = lines
+	markChanged()
+	return nil
+}
+
+// PurchaseLine builds a one-unit line for itemID with the same rules as AddToCart (catalog, current
+// effective price, ownership, per-kind cap, stock) without touching the cart. Used for instant purchases.
+func PurchaseLine(itemID string, owned []string) (models.CartItem, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	lines, err := addItem(nil, itemID, 1, owned)
+	if err != nil {
+		return models.CartItem{}, err
+	}
+	return lines[0], nil
+}
+
+// addItem adds quantity units of itemID to lines (merging into an existing line), applying every
+// cart rule. lines may be modified in place. Callers hold mu.
+func addItem(lines []models.CartItem, itemID string, quantity int, owned []string) ([]models.CartItem, error) {
 	name, price, kind, ok := models.ItemDisplay(itemID)
 	if !ok {
-		return ErrUnknownItem
+		return nil, ErrUnknownItem
 	}
 	if itemID == "default" && price == 0 {
-		return ErrDefaultSkin
+		return nil, ErrDefaultSkin
 	}
-	mu.Lock() // protect the cart field
-	defer mu.Unlock() // unlock the cart field
-	for i := range cart { // check if the item is already in the cart
-		if cart[i].ItemID == itemID {
-			cart[i].Quantity++
-			return nil
+	if kind == models.ItemKindSkin {
+		for _, s := range owned {
+			if s == itemID {
+				return nil, ErrAlreadyOwned
+			}
+		}
+	}
+	if quantity < 1 {
+		return nil, ErrInvalidQuantity
+	}
+	for i := range lines { // check if the item is already in the cart
+		if lines[i].ItemID == itemID {
+			if err := checkLine(lines[i], lines[i].Quantity+quantity); err != nil {
+				return nil, err
+			}
+			lines[i].Quantity += quantity
+			return lines, nil
 		}
 	}
-	cart = append(cart, models.CartItem{
-		ID: newCartLineID(), ItemID: itemID, Name: name, Price: price, Quantity: 1, Kind: kind,
-	})
+	listPrice := price
+	if p, okPricing := models.ItemPricing(itemID); okPricing {
+		listPrice = p.Original
+	}
+	line := models.CartItem{
+		ID: newCartLineID(), ItemID: itemID, Name: name, Price: price, OriginalPrice: listPrice, Quantity: quantity, Kind: kind,
+	}
+	if err := checkLine(line, quantity); err != nil {
+		return nil, err
+	}
+	return append(lines, line), nil
+}
+
+// checkLine validates a line quantity against the per-kind cap and remaining stock. Callers hold mu.
+func checkLine(it models.CartItem, quantity int) error {
+	if err := checkQuantity(it, quantity); err != nil {
+		return err
+	}
+	if level, limited := models.Stock(it.ItemID); limited && level.Remaining < quantity {
+		return ErrSoldOut
+	}
 	return nil
 }
 
 // GetCart returns a copy of cart items and the total price (sum of price*quantity per line).
 func GetCart() ([]models.CartItem, int) {
+	items, total, _ := GetCartWithVersion()
+	return items, total
+}
+
+// GetCartWithVersion is GetCart plus the cart version, read atomically (for ETag responses).
+func GetCartWithVersion() ([]models.CartItem, int, uint64) {
 	mu.RLock()
 	defer mu.RUnlock()
 	if len(cart) == 0 {
-		return nil, 0
+		return nil, 0, version
 	}
 	out := make([]models.CartItem, len(cart))
 	var total int
@@ -65,40 +200,102 @@ func GetCart() ([]models.CartItem, int) {
 		out[i] = cart[i]
 		total += cart[i].Price * cart[i].Quantity
 	}
-	return out, total
+	return out, total, version
+}
+
+// CartVersion returns the current cart version.
+func CartVersion() uint64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	return version
+}
+
+// touch bumps the version for changes made outside the cart lines (e.g. attaching a coupon).
+func touch() {
+	mu.Lock()
+	defer mu.Unlock()
+	markChanged()
 }
 
 // UpdateCartItem sets the quantity for the cart line with the given id. If quantity < 1, the line is removed.
+// Quantities above the per-kind cap are rejected with ErrQuantityLimit.
 func UpdateCartItem(id string, quantity int) error {
-	if quantity < 1 {
-		mu.Lock()
-		defer mu.Unlock()
-		for i := range cart {
-			if cart[i].ID == id {
-				cart = append(cart[:i], cart[i+1:]...)
-				return nil
-			}
-		}
-		return ErrCartItemNotFound
-	}
 	mu.Lock()
 	defer mu.Unlock()
-	for i := range cart {
-		if cart[i].ID == id {
-			cart[i].Quantity = quantity
-			return nil
+	return updateCartItem(id, quantity)
+}
+
+// UpdateCartItemIfMatch is UpdateCartItem that fails with ErrVersionMismatch unless the cart is still at expected.
+func UpdateCartItemIfMatch(id string, quantity int, expected uint64) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if version != expected {
+		return ErrVersionMismatch
+	}
+	return updateCartItem(id, quantity)
+}
+
+// updateCartItem applies a quantity change to the cart. Callers hold mu.
+func updateCartItem(id string, quantity int) error {
+	lines, err := updateLine(cart, id, quantity)
+	if err != nil {
+		return err
+	}
+	cart = lines
+	markChanged()
+	return nil
+}
+
+// updateLine sets a line's quantity (removing it when quantity < 1), enforcing the per-kind cap and
+// stock when the quantity grows. lines may be modified in place. Callers hold mu.
+func updateLine(lines []models.CartItem, id string, quantity int) ([]models.CartItem, error) {
+	for i := range lines {
+		if lines[i].ID == id {
+			if quantity < 1 {
+				return append(lines[:i], lines[i+1:]...), nil
+			}
+			if err := checkQuantity(lines[i], quantity); err != nil {
+				return nil, err
+			}
+			if quantity > lines[i].Quantity {
+				if err := checkLine(lines[i], quantity); err != nil {
+					return nil, err
+				}
+			}
+			lines[i].Quantity = quantity
+			return lines, nil
 		}
 	}
-	return ErrCartItemNotFound
+	return nil, ErrCartItemNotFound
 }
 
 // RemoveCartItemByID removes the cart line with the given id. Returns true if removed.
 func RemoveCartItemByID(id string) bool {
 	mu.Lock()
 	defer mu.Unlock()
+	return removeCartItemByID(id)
+}
+
+// RemoveCartItemByIDIfMatch removes the cart line only if the cart is still at expected.
+// Returns ErrVersionMismatch or ErrCartItemNotFound on failure.
+func RemoveCartItemByIDIfMatch(id string, expected uint64) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if version != expected {
+		return ErrVersionMismatch
+	}
+	if !removeCartItemByID(id) {
+		return ErrCartItemNotFound
+	}
+	return nil
+}
+
+// removeCartItemByID removes a line by id. Callers hold mu.
+func removeCartItemByID(id string) bool {
 	for i := range cart {
 		if cart[i].ID == id {
 			cart = append(cart[:i], cart[i+1:]...)
+			markChanged()
 			return true
 		}
 	}
@@ -116,6 +313,7 @@ func RemoveCartItem(itemID string) bool {
 			} else {
 				cart = append(cart[:i], cart[i+1:]...)
 			}
+			markChanged()
 			return true
 		}
 	}
@@ -127,6 +325,23 @@ func ClearCart() {
 	mu.Lock()
 	defer mu.Unlock()
 	cart = nil
+	markChanged()
+}
+
+// ClearPurchased empties the cart after a checkout of the cart at purchasedVersion. If the cart was
+// modified meanwhile (e.g. from another tab), only the purchased line ids are removed so later
+// additions survive.
+func ClearPurchased(purchasedVersion uint64, lineIDs []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if version == purchasedVersion {
+		cart = nil
+		markChanged()
+		return
+	}
+	for _, id := range lineIDs {
+		removeCartItemByID(id)
+	}
 }
 
 // CartCount returns the number of lines in the cart.
@@ -135,3 +350,61 @@ func CartCount() int {
 	defer mu.RUnlock()
 	return len(cart)
 }
+
+// PriceChange describes a cart line whose stored price no longer matches the catalog.
+type PriceChange struct {
+	LineID   string `json:"id"`
+	ItemID   string `json:"itemId"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	OldPrice int    `json:"oldPrice"`
+	NewPrice int    `json:"newPrice"`
+}
+
+// RepriceCart revalidates every line against the current catalog. Lines whose item no longer exists
+// are removed and returned in removed; lines whose effective price changed are updated in place and
+// returned in changed. Both are empty when the cart already matches the catalog.
+func RepriceCart() (changed []PriceChange, removed []models.CartItem) {
+	mu.Lock()
+	defer mu.Unlock()
+	cart, changed, removed = repriceLines(cart)
+	if len(changed) > 0 || len(removed) > 0 {
+		markChanged()
+	}
+	return changed, removed
+}
+
+// PreviewReprice is RepriceCart on a copy: it returns the cart as checkout would see it (with the
+// version it was read at) without modifying anything.
+func PreviewReprice() (items []models.CartItem, cartVersion uint64, changed []PriceChange, removed []models.CartItem) {
+	mu.RLock()
+	defer mu.RUnlock()
+	working := make([]models.CartItem, len(cart))
+	copy(working, cart)
+	items, changed, removed = repriceLines(working)
+	return items, version, changed, removed
+}
+
+// repriceLines updates lines to current catalog prices, dropping lines for unknown items. lines is
+// modified in place.
+func repriceLines(lines []models.CartItem) (kept []models.CartItem, changed []PriceChange, removed []models.CartItem) {
+	kept = lines[:0]
+	for _, it := range lines {
+		name, price, _, ok := models.ItemDisplay(it.ItemID)
+		if !ok {
+			removed = append(removed, it)
+			continue
+		}
+		if price != it.Price {
+			changed = append(changed, PriceChange{
+				LineID: it.ID, ItemID: it.ItemID, Name: name, Quantity: it.Quantity, OldPrice: it.Price, NewPrice: price,
+			})
+			it.Price = price
+			if p, okPricing := models.ItemPricing(it.ItemID); okPricing {
+				it.OriginalPrice = p.Original
+			}
+		}
+		kept = append(kept, it)
+	}
+	return kept, changed, removed
+}
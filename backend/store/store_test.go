@@ -0,0 +1,95 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"SnakeGame/models"
+)
+
+func TestRepriceCart(t *testing.T) {
+	ClearCart()
+	t.Cleanup(ClearCart)
+	if err := AddToCart("skin_ice", nil); err != nil {
+		t.Fatalf("AddToCart: %v", err)
+	}
+	AddToCart("extra_life", nil)
+	mu.Lock()
+	cart = append(cart, models.CartItem{ID: "gone-line", ItemID: "skin_retired", Name: "Retired", Price: 10, Quantity: 1})
+	mu.Unlock()
+
+	sale, err := models.AddPriceRule(models.PriceRule{ItemIDs: []string{"skin_ice"}, PercentOff: 50, EndsAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("AddPriceRule: %v", err)
+	}
+	t.Cleanup(func() { models.RemovePriceRule(sale.ID) })
+
+	changed, removed := RepriceCart()
+	if len(changed) != 1 || changed[0].ItemID != "skin_ice" || changed[0].OldPrice != 100 || changed[0].NewPrice != 50 {
+		t.Errorf("unexpected price changes: %+v", changed)
+	}
+	if len(removed) != 1 || removed[0].ItemID != "skin_retired" {
+		t.Errorf("unexpected removed lines: %+v", removed)
+	}
+	items, total := GetCart()
+	if len(items) != 2 || total != 100 {
+		t.Errorf("cart after reprice: want 2 lines totalling 100, got %d lines totalling %d", len(items), total)
+	}
+	if changed, removed := RepriceCart(); len(changed) != 0 || len(removed) != 0 {
+		t.Errorf("second reprice should be a no-op, got %+v %+v", changed, removed)
+	}
+}
+
+func TestAddToCart_KindRules(t *testing.T) {
+	ClearCart()
+	t.Cleanup(ClearCart)
+	t.Cleanup(func() { SetMaxConsumableQuantity(DefaultMaxConsumableQuantity) })
+	SetMaxConsumableQuantity(2)
+
+	if err := AddToCart("skin_gold", []string{"default", "skin_gold"}); !errors.Is(err, ErrAlreadyOwned) {
+		t.Errorf("owned skin: want ErrAlreadyOwned, got %v", err)
+	}
+	if err := AddToCart("skin_fire", []string{"default"}); err != nil {
+		t.Fatalf("AddToCart: %v", err)
+	}
+	if err := AddToCart("skin_fire", []string{"default"}); !errors.Is(err, ErrQuantityLimit) {
+		t.Errorf("second skin: want ErrQuantityLimit, got %v", err)
+	}
+	AddToCart("extra_life", nil)
+	AddToCart("extra_life", nil)
+	if err := AddToCart("extra_life", nil); !errors.Is(err, ErrQuantityLimit) {
+		t.Errorf("third life over cap 2: want ErrQuantityLimit, got %v", err)
+	}
+
+	items, _ := GetCart()
+	for _, it := range items {
+		want := 2
+		if it.Kind == models.ItemKindSkin {
+			want = 1
+		}
+		if err := UpdateCartItem(it.ID, want+1); !errors.Is(err, ErrQuantityLimit) {
+			t.Errorf("update %s to %d: want ErrQuantityLimit, got %v", it.ItemID, want+1, err)
+		}
+	}
+}
+
+func TestCartChanged_Signalled(t *testing.T) {
+	ClearCart()
+	select { // drain a pending signal from earlier changes
+	case <-CartChanged():
+	default:
+	}
+	AddToCart("extra_life", nil)
+	AddToCart("extra_life", nil)
+	select {
+	case <-CartChanged():
+	default:
+		t.Fatal("a cart change must signal CartChanged")
+	}
+	select {
+	case <-CartChanged():
+		t.Error("a burst of changes must coalesce into one signal")
+	default:
+	}
+}
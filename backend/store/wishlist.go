@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"SnakeGame/models"
+)
+
+// ErrAlreadyWishlisted is returned when adding an item that is already on the wishlist.
+var ErrAlreadyWishlisted = errors.New("item already on wishlist")
+
+// ErrNotWishlisted is returned when an item is not on the wishlist.
+var ErrNotWishlisted = errors.New("item not on wishlist")
+
+// WishlistEntry is an item the player saved for later, with its current pricing.
+type WishlistEntry struct {
+	ItemID        string    `json:"itemId"`
+	Name          string    `json:"name"`
+	Price         int       `json:"price"`
+	OriginalPrice int       `json:"originalPrice"`
+	OnSale        bool      `json:"onSale"`
+	AddedAt       time.Time `json:"addedAt"`
+}
+
+// Notification types.
+const (
+	NotificationSale       = "wishlist.sale"       // a wishlisted item went on sale
+	NotificationAffordable = "wishlist.affordable" // the balance crossed a wishlisted item's price
+)
+
+// Notification is a record shown to the player (newest last).
+type Notification struct {
+	ID        int       `json:"id"`
+	Type      string    `json:"type"`
+	ItemID    string    `json:"itemId"`
+	Name      string    `json:"name"`
+	Price     int       `json:"price"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// wishItem is a stored wishlist entry. saleRule is the price rule already notified about, so one
+// sale produces one notification.
+type wishItem struct {
+	itemID   string
+	addedAt  time.Time
+	saleRule string
+}
+
+var (
+	wishlistMu    sync.Mutex     // protects wishlist and notifications
+	wishlist      []*wishItem    // in insertion order
+	notifications []Notification // newest last
+)
+
+// AddToWishlist saves a catalog item the player does not own yet.
+func AddToWishlist(itemID string, owned []string) error {
+	p, ok := models.ItemPricing(itemID)
+	if !ok {
+		return ErrUnknownItem
+	}
+	if itemID == "default" {
+		return ErrDefaultSkin
+	}
+	if models.IsSkin(itemID) {
+		for _, s := range owned {
+			if s == itemID {
+				return ErrAlreadyOwned
+			}
+		}
+	}
+	wishlistMu.Lock()
+	defer wishlistMu.Unlock()
+	for _, w := range wishlist {
+		if w.itemID == itemID {
+			return ErrAlreadyWishlisted
+		}
+	}
+	// A sale already running when the item is saved is not news to the player.
+	wishlist = append(wishlist, &wishItem{itemID: itemID, addedAt: now(), saleRule: p.RuleID})
+	return nil
+}
+
+// RemoveFromWishlist deletes an item from the wishlist.
+func RemoveFromWishlist(itemID string) error {
+	wishlistMu.Lock()
+	defer wishlistMu.Unlock()
+	for i, w := range wishlist {
+		if w.itemID == itemID {
+			wishlist = append(wishlist[:i], wishlist[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotWishlisted
+}
+
+// Wishlist returns the saved items with their current prices.
+func Wishlist() []WishlistEntry {
+	wishlistMu.Lock()
+	defer wishlistMu.Unlock()
+	out := make([]WishlistEntry, 0, len(wishlist))
+	for _, w := range wishlist {
+		name, _, _, _ := models.ItemDisplay(w.itemID)
+		p, _ := models.ItemPricing(w.itemID)
+		out = append(out, WishlistEntry{
+			ItemID: w.itemID, Name: name, Price: p.Effective, OriginalPrice: p.Original, OnSale: p.OnSale(), AddedAt: w.addedAt,
+		})
+	}
+	return out
+}
+
+// MoveWishlistToCart adds one unit of a wishlisted item to the cart and removes it from the wishlist.
+// The wishlist is unchanged if the cart rejects the item.
+func MoveWishlistToCart(itemID string, owned []string) error {
+	wishlistMu.Lock()
+	found := false
+	for _, w := range wishlist {
+		if w.itemID == itemID {
+			found = true
+		}
+	}
+	wishlistMu.Unlock()
+	if !found {
+		return ErrNotWishlisted
+	}
+	if err := AddToCart(itemID, owned); err != nil {
+		return err
+	}
+	RemoveFromWishlist(itemID)
+	return nil
+}
+
+// Notifications returns a copy of the player's notification records.
+func Notifications() []Notification {
+	wishlistMu.Lock()
+	defer wishlistMu.Unlock()
+	return append([]Notification(nil), notifications...)
+}
+
+// notify appends a notification. Callers hold wishlistMu.
+func notify(kind, itemID, name string, price int, message string) Notification {
+	n := Notification{
+		ID: len(notifications) + 1, Type: kind, ItemID: itemID, Name: name, Price: price, Message: message, CreatedAt: now(),
+	}
+	notifications = append(notifications, n)
+	return n
+}
+
+// CheckWishlistSales records a notification for each wishlisted item that went on sale since the
+// last check (once per price rule). Returns the new notifications.
+func CheckWishlistSales() []Notification {
+	wishlistMu.Lock()
+	defer wishlistMu.Unlock()
+	var out []Notification
+	for _, w := range wishlist {
+		p, ok := models.ItemPricing(w.itemID)
+		if !ok || !p.OnSale() {
+			w.saleRule = ""
+			continue
+		}
+		if p.RuleID == w.saleRule {
+			continue
+		}
+		w.saleRule = p.RuleID
+		name, _, _, _ := models.ItemDisplay(w.itemID)
+		out = append(out, notify(NotificationSale, w.itemID, name, p.Effective,
+			fmt.Sprintf("%s is on sale: %d coins (was %d)", name, p.Effective, p.Original)))
+	}
+	return out
+}
+
+// CheckWishlistAffordability records a notification for each wishlisted item whose price the balance
+// crossed, i.e. previous < price <= balance. Returns the new notifications.
+func CheckWishlistAffordability(previous, balance int) []Notification {
+	if balance <= previous {
+		return nil
+	}
+	wishlistMu.Lock()
+	defer wishlistMu.Unlock()
+	var out []Notification
+	for _, w := range wishlist {
+		name, price, _, ok := models.ItemDisplay(w.itemID)
+		if !ok || previous >= price || balance < price {
+			continue
+		}
+		out = append(out, notify(NotificationAffordable, w.itemID, name, price,
+			fmt.Sprintf("You can now afford %s (%d coins)", name, price)))
+	}
+	return out
+}
+
+// StartWishlistWatcher runs CheckWishlistSales every interval until ctx is cancelled, so sales that
+// start on schedule produce notifications without any request.
+func StartWishlistWatcher(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				CheckWishlistSales()
+			}
+		}
+	}()
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"SnakeGame/models"
+)
+
+func TestWishlist_Notifications(t *testing.T) {
+	t.Cleanup(func() { RemoveFromWishlist("skin_rainbow"); ClearCart() })
+	if err := AddToWishlist("skin_gold", []string{"default", "skin_gold"}); !errors.Is(err, ErrAlreadyOwned) {
+		t.Errorf("owned skin: want ErrAlreadyOwned, got %v", err)
+	}
+	if err := AddToWishlist("skin_rainbow", []string{"default"}); err != nil {
+		t.Fatalf("AddToWishlist: %v", err)
+	}
+	if err := AddToWishlist("skin_rainbow", nil); !errors.Is(err, ErrAlreadyWishlisted) {
+		t.Errorf("duplicate: want ErrAlreadyWishlisted, got %v", err)
+	}
+
+	if got := CheckWishlistAffordability(40, 90); len(got) != 0 {
+		t.Errorf("balance below price must not notify, got %+v", got)
+	}
+	if got := CheckWishlistAffordability(90, 120); len(got) != 1 || got[0].Type != NotificationAffordable {
+		t.Errorf("crossing 100: want one affordable notification, got %+v", got)
+	}
+	if got := CheckWishlistAffordability(120, 150); len(got) != 0 {
+		t.Errorf("already above price must not notify again, got %+v", got)
+	}
+
+	sale, _ := models.AddPriceRule(models.PriceRule{ItemIDs: []string{"skin_rainbow"}, PercentOff: 20, EndsAt: time.Now().Add(time.Hour)})
+	t.Cleanup(func() { models.RemovePriceRule(sale.ID) })
+	if got := CheckWishlistSales(); len(got) != 1 || got[0].Price != 80 {
+		t.Errorf("sale start: want one sale notification at 80, got %+v", got)
+	}
+	if got := CheckWishlistSales(); len(got) != 0 {
+		t.Errorf("same sale must notify once, got %+v", got)
+	}
+
+	ClearCart()
+	if err := MoveWishlistToCart("skin_rainbow", nil); err != nil {
+		t.Fatalf("MoveWishlistToCart: %v", err)
+	}
+	if len(Wishlist()) != 0 || CartCount() != 1 {
+		t.Errorf("item should move from wishlist to cart: wishlist=%d cart=%d", len(Wishlist()), CartCount())
+	}
+}
@@ -3,7 +3,7 @@
  * Depends on globals defined in index.html: state, skins, toast, apiGet, apiPost, apiPatch, apiDelete, loadPlayer
  */
 
-const cartData = { items: [], total: 0 };
+const cartData = { items: [], total: 0, quote: null };
 
 async function loadCart() {
     try {
@@ -14,12 +14,26 @@ async function loadCart() {
         cartData.items = [];
         cartData.total = 0;
     }
+    await loadQuote();
+}
+
+// The quote prices the cart exactly as checkout will (owned skins skipped, coupons applied).
+async function loadQuote() {
+    try {
+        cartData.quote = await apiGet('/api/user/cart/quote');
+    } catch (e) {
+        cartData.quote = null;
+    }
+}
+
+function chargeTotal() {
+    return cartData.quote && cartData.quote.quote ? cartData.quote.quote.total : cartData.total;
 }
 
 function renderCart() {
     const itemCount = cartData.items.reduce((n, it) => n + (it.quantity || 1), 0);
     document.getElementById('cartCount').textContent = '(' + itemCount + ')';
-    document.getElementById('cartTotal').textContent = cartData.total;
+    document.getElementById('cartTotal').textContent = chargeTotal();
 
     const listEl = document.getElementById('cartList');
     if (cartData.items.length === 0) {
@@ -28,7 +42,9 @@ function renderCart() {
         return;
     }
 
-    document.getElementById('btnCheckout').disabled = state.balance < cartData.total;
+    document.getElementById('btnCheckout').disabled = cartData.quote
+        ? !cartData.quote.canCheckout && !(cartData.quote.priceChanges || []).length
+        : state.balance < cartData.total;
     listEl.innerHTML = cartData.items.map(it => {
         const qty = it.quantity ?? 1;
         const lineTotal = (it.price || 0) * qty;
@@ -67,7 +83,7 @@ function renderCart() {
                 await loadCart();
                 renderCart();
                 toast('Cart updated', 'success');
-            } catch (e) { toast('Could not update quantity', 'error'); }
+            } catch (e) { toast((e && e.json && e.json.error) || 'Could not update quantity', 'error'); }
         };
     });
 }
@@ -140,6 +156,7 @@ async function addToCart(itemId, price) {
         const res = await apiPost('/api/user/cart/items', { itemId });
         cartData.items = res.items || [];
         cartData.total = res.total || 0;
+        await loadQuote();
         renderCart();
         toast('Added to cart', 'success');
     } catch (e) {
@@ -149,7 +166,7 @@ async function addToCart(itemId, price) {
 
 async function checkoutCart() {
     if (cartData.items.length === 0) { toast('Cart is empty', 'error'); return; }
-    if (state.balance < cartData.total) { toast('Not enough coins', 'error'); return; }
+    if (state.balance < chargeTotal()) { toast('Not enough coins', 'error'); return; }
     try {
         const res = await apiPost('/api/user/orders', {});
         if (res.Status === 'Success') {
@@ -163,7 +180,41 @@ async function checkoutCart() {
             document.getElementById('storeCoins').textContent = state.balance;
             toast(res.Message || 'Purchase complete!', 'success');
         } else {
+            if (res.PriceChanges || res.RemovedItems) {
+                // Cart was repriced server-side; show the new total so the player can confirm it.
+                await loadCart();
+                renderCart();
+            }
             toast(res.Message || 'Checkout failed', 'error');
         }
     } catch (e) { toast('Checkout failed', 'error'); }
-}
\ No newline at end of file
+}
+
+// Live updates: balance, skins, lives and cart changes made in another tab (or by the game) arrive
+// over Server-Sent Events, so the store never shows stale values.
+function subscribeLive(playerId) {
+    if (!window.EventSource) return;
+    const es = new EventSource('/api/players/' + encodeURIComponent(playerId) + '/events');
+    es.addEventListener('balance', e => {
+        state.balance = JSON.parse(e.data).balance;
+        document.getElementById('storeCoins').textContent = state.balance;
+    });
+    es.addEventListener('skins', e => {
+        const d = JSON.parse(e.data);
+        state.ownedSkins   = d.ownedSkins   || state.ownedSkins;
+        state.equippedSkin = d.equippedSkin || state.equippedSkin;
+    });
+    es.addEventListener('lives', e => {
+        state.extraLives = Math.max(0, Number(JSON.parse(e.data).extraLives) || 0);
+    });
+    es.addEventListener('cart', async e => {
+        const c = JSON.parse(e.data);
+        cartData.items = c.items || [];
+        cartData.total = c.total || 0;
+        await loadQuote();
+        renderCart();
+    });
+}
+
+// Subscribe as the player /api/player returns once it has loaded.
+loadPlayer().then(() => { if (state.playerId) subscribeLive(state.playerId); });
@@ -32,11 +32,11 @@ echo -e "\n"
 echo "=== 6. GET /api/user/cart ==="
 CART=$(curl -s -X GET "$BASE/api/user/cart")
 echo "$CART"
-# Extract first cart item id for PATCH/DELETE (requires jq; otherwise skip or use a fixed id)
-CART_ITEM_ID=$(echo "$CART" | grep -o '"id":"[^"]*"' | head -1 | cut -d'"' -f4)
+# Extract the extra_life line id for PATCH (skins are capped at quantity 1, so a skin line cannot go to 2)
+CART_ITEM_ID=$(echo "$CART" | grep -o '"id":"[^"]*","itemId":"extra_life"' | head -1 | cut -d'"' -f4)
 echo -e "\n"
 
-echo "=== 7. PATCH /api/user/cart/items/{id} (update quantity; id from cart) ==="
+echo "=== 7. PATCH /api/user/cart/items/{id} (update extra life quantity; id from cart) ==="
 if [ -n "$CART_ITEM_ID" ]; then
   curl -s -X PATCH "$BASE/api/user/cart/items/$CART_ITEM_ID" \
     -H "Content-Type: application/json" \
@@ -108,11 +108,9 @@ curl -s -X POST "$BASE/api/user/cart/items" \
   -d '{"itemId": ""}'
 echo -e "\n"
 
-echo "=== 19. Optional: checkout with payment timeout simulation ==="
-# Add item first
-curl -s -X POST "$BASE/api/user/cart/items" -H "Content-Type: application/json" -d '{"itemId": "extra_life"}' > /dev/null
-curl -s -X POST "$BASE/api/user/orders" \
-  -H "Content-Type: application/json" \
+echo "=== 19. Optional: coin pack purchase with payment timeout simulation ==="
+# Only coin packs reach the payment gateway; checkout pays in coins.
+curl -s -X POST "$BASE/api/user/coin-packs/coins_500/purchase" \
   -H "X-Simulate-Payment-Timeout: true" \
   -H "Idempotency-Key: sim-timeout-$(date +%s)"
 echo -e "\n"